@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// alertRule fires when a single source IP address hits the rule Threshold
+// times within Window, e.g. "more than 100 hits on port 22 from one IP in
+// 10 minutes". Empty Port, Protocol and Source match every request and a
+// zero Window counts over the whole run. A rule with a Preset starts from
+// the alert preset of that name.
+type alertRule struct {
+	Preset    string `json:"preset"`
+	Name      string `json:"name"`
+	Port      string `json:"port"`
+	Protocol  string `json:"protocol"`
+	Source    string `json:"source"`
+	Threshold int    `json:"threshold"`
+	Window    string `json:"window"`
+	Action    string `json:"action"`
+	URL       string `json:"url"`
+	Command   string `json:"command"`
+
+	window time.Duration
+	source *net.IPNet
+}
+
+// alertEvent is a single firing of an alert rule.
+type alertEvent struct {
+	Rule      string    `json:"rule"`
+	IPAddress string    `json:"ip"`
+	Hits      int       `json:"hits"`
+	Time      time.Time `json:"time"`
+}
+
+// compile applies the preset of the rule, validates it and parses its
+// window and source network.
+func (rule *alertRule) compile() error {
+	if rule.Preset != "" {
+		if err := rule.applyPreset(); err != nil {
+			return err
+		}
+	}
+	if rule.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if rule.Threshold <= 0 {
+		rule.Threshold = 1
+	}
+	if rule.Window != "" {
+		window, err := time.ParseDuration(rule.Window)
+		if err != nil {
+			return fmt.Errorf("%s: %v", rule.Name, err)
+		}
+		rule.window = window
+	}
+	if rule.Source != "" {
+		network, err := parseNetwork(rule.Source)
+		if err != nil {
+			return fmt.Errorf("%s: %v", rule.Name, err)
+		}
+		rule.source = network
+	}
+
+	switch rule.Action {
+	case "", "print":
+		rule.Action = "print"
+	case "webhook":
+		if rule.URL == "" {
+			return fmt.Errorf("%s: webhook action without url", rule.Name)
+		}
+	case "exec":
+		if rule.Command == "" {
+			return fmt.Errorf("%s: exec action without command", rule.Name)
+		}
+	default:
+		return fmt.Errorf("%s: unknown action %q, use print, webhook or exec", rule.Name, rule.Action)
+	}
+	return nil
+}
+
+// matches reports whether a request is counted by the rule.
+func (rule *alertRule) matches(ipAddress string, portNumber string, protocol string) bool {
+	if rule.Port != "" && rule.Port != portNumber {
+		return false
+	}
+	if rule.Protocol != "" && !strings.EqualFold(rule.Protocol, protocol) {
+		return false
+	}
+	if rule.source != nil {
+		ip := net.ParseIP(ipAddress)
+		if ip == nil || !rule.source.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// alertEngine evaluates the alert rules incrementally for every request.
+// stats counts the requests every rule matches. It is safe for concurrent
+// use.
+type alertEngine struct {
+	sync.Mutex
+	rules  []alertRule
+	stats  []*groupStats
+	hits   []map[string][]time.Time
+	fired  []alertEvent
+	silent bool
+	client *http.Client
+}
+
+// newAlertEngine returns an engine evaluating rules. When silent is set
+// fired alerts are only recorded and their actions are not run.
+func newAlertEngine(rules []alertRule, silent bool) *alertEngine {
+	engine := &alertEngine{
+		rules:  rules,
+		silent: silent,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	for range rules {
+		engine.hits = append(engine.hits, make(map[string][]time.Time))
+		engine.stats = append(engine.stats, newGroupStats())
+	}
+	return engine
+}
+
+// observe counts a request for every matching rule and runs the action of
+// the rules that reach their threshold within their window, counted back
+// from the newest request of the IP address. A rule that fired for an IP
+// address starts counting that address from zero again.
+func (engine *alertEngine) observe(timestamp time.Time, ipAddress string, portNumber string, protocol string) {
+	var fired []int
+	var events []alertEvent
+
+	engine.Lock()
+	for i := range engine.rules {
+		rule := &engine.rules[i]
+		if !rule.matches(ipAddress, portNumber, protocol) {
+			continue
+		}
+		engine.stats[i].count(ipAddress, timestamp, !timestamp.IsZero())
+
+		// With more than one file read at the same time the requests
+		// arrive out of order. The hits are kept sorted by their
+		// timestamp and the window ends at the newest one, so the hits
+		// in the window don't depend on the order of the requests.
+		hits := engine.hits[i][ipAddress]
+		at := sort.Search(len(hits), func(j int) bool { return hits[j].After(timestamp) })
+		hits = append(hits, time.Time{})
+		copy(hits[at+1:], hits[at:])
+		hits[at] = timestamp
+		if rule.window > 0 {
+			newest := hits[len(hits)-1]
+			start := 0
+			for start < len(hits) && newest.Sub(hits[start]) > rule.window {
+				start++
+			}
+			hits = hits[start:]
+		}
+
+		if len(hits) >= rule.Threshold {
+			event := alertEvent{Rule: rule.Name, IPAddress: ipAddress, Hits: len(hits), Time: timestamp}
+			engine.fired = append(engine.fired, event)
+			fired = append(fired, i)
+			events = append(events, event)
+			hits = nil
+		}
+		engine.hits[i][ipAddress] = hits
+	}
+	engine.Unlock()
+
+	if engine.silent {
+		return
+	}
+	for i, ruleIndex := range fired {
+		if err := engine.run(&engine.rules[ruleIndex], events[i]); err != nil {
+			fmt.Fprintf(os.Stderr, "alert %s: %v\n", events[i].Rule, err)
+		}
+	}
+}
+
+// run executes the action of rule for event.
+func (engine *alertEngine) run(rule *alertRule, event alertEvent) error {
+	switch rule.Action {
+	case "webhook":
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		response, err := engine.client.Post(rule.URL, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return fmt.Errorf("webhook: %s", response.Status)
+		}
+		return nil
+	case "exec":
+		command := exec.Command("sh", "-c", rule.Command)
+		command.Env = append(os.Environ(),
+			"UFW_ALERT_RULE="+event.Rule,
+			"UFW_ALERT_IP="+event.IPAddress,
+			"UFW_ALERT_HITS="+strconv.Itoa(event.Hits),
+			"UFW_ALERT_TIME="+event.Time.Format(time.RFC3339),
+		)
+		command.Stdout = os.Stderr
+		command.Stderr = os.Stderr
+		return command.Run()
+	default:
+		fmt.Fprintln(os.Stderr, formatAlertEvent(event))
+		return nil
+	}
+}
+
+// firedAlerts returns the alerts fired so far.
+func (engine *alertEngine) firedAlerts() []alertEvent {
+	engine.Lock()
+	defer engine.Unlock()
+	return append([]alertEvent(nil), engine.fired...)
+}
+
+// formatAlertEvent formats an alert for printing.
+func formatAlertEvent(event alertEvent) string {
+	timestamp := "-"
+	if !event.Time.IsZero() {
+		timestamp = event.Time.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprintf("ALERT %s\t%s\t%s\t%d hits", timestamp, event.Rule, event.IPAddress, event.Hits)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertWindowOutOfOrder(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		offsets []time.Duration
+		fired   int
+	}{
+		{"within window", []time.Duration{0, 30 * time.Second, 50 * time.Second}, 1},
+		{"oldest outside window", []time.Duration{0, 100 * time.Second, 110 * time.Second}, 0},
+	}
+	orders := [][3]int{{0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0}}
+	for _, test := range tests {
+		for _, order := range orders {
+			rule := alertRule{Name: "ssh", Port: "22", Threshold: 3, Window: "1m"}
+			if err := rule.compile(); err != nil {
+				t.Fatal(err)
+			}
+			engine := newAlertEngine([]alertRule{rule}, true)
+			for _, i := range order {
+				engine.observe(start.Add(test.offsets[i]), "45.155.205.12", "22", "TCP")
+			}
+			if fired := len(engine.firedAlerts()); fired != test.fired {
+				t.Errorf("%s in order %v: fired %d alerts, want %d", test.name, order, fired, test.fired)
+			}
+		}
+	}
+}
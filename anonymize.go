@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// The prefix lengths of the networks anonymized IP addresses keep.
+const (
+	anonymizePrefix4 = 24
+	anonymizePrefix6 = 48
+)
+
+// anonymizer replaces IP addresses by their /24 (IPv4) or /48 (IPv6)
+// network. In hash mode a keyed hash of the address is appended, so the
+// same address always gets the same pseudonym, e.g. 203.0.113.0/24#3fa2c1,
+// while the address can't be recovered without the key.
+type anonymizer struct {
+	hash bool
+	key  []byte
+}
+
+// newAnonymizer returns an anonymizer for mode, mask or hash. Without a key
+// a random key is used, the pseudonyms are then only consistent within a
+// single run.
+func newAnonymizer(mode string, key string) (*anonymizer, error) {
+	switch mode {
+	case "mask":
+		return &anonymizer{}, nil
+	case "hash":
+		anonymizer := &anonymizer{hash: true, key: []byte(key)}
+		if key == "" {
+			anonymizer.key = make([]byte, 32)
+			if _, err := rand.Read(anonymizer.key); err != nil {
+				return nil, err
+			}
+		}
+		return anonymizer, nil
+	}
+	return nil, fmt.Errorf("unknown anonymization mode %q, use mask or hash", mode)
+}
+
+// anonymize returns the pseudonym of ipAddress.
+func (anonymizer *anonymizer) anonymize(ipAddress string) string {
+	network := subnetOf(ipAddress, anonymizePrefix4, anonymizePrefix6)
+	if !anonymizer.hash {
+		return network
+	}
+	mac := hmac.New(sha256.New, anonymizer.key)
+	mac.Write([]byte(ipAddress))
+	return network + "#" + hex.EncodeToString(mac.Sum(nil)[:3])
+}
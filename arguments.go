@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// logFilePattern matches the names of the log files read from directory
+// arguments, including rotated files like ufw.log.2.gz.
+const logFilePattern = "*.log*"
+
+// expandArguments returns the files of the file arguments. Directories are
+// scanned recursively for files matching logFilePattern in lexical order
+// and arguments that are not a file but a glob pattern are replaced by the
+// files matching it. The files of directories and patterns whose name or
+// path matches one of the exclude patterns are left out. Other arguments
+// are kept as they are, so reading a file that doesn't exist reports the
+// error.
+func expandArguments(arguments []string, exclude []string) ([]string, error) {
+	var files []string
+	add := func(filename string) {
+		if !isExcluded(filename, exclude) {
+			files = append(files, filename)
+		}
+	}
+	for _, argument := range arguments {
+		info, err := os.Stat(argument)
+		switch {
+		case err == nil && info.IsDir():
+			err := filepath.WalkDir(argument, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if matched, _ := filepath.Match(logFilePattern, entry.Name()); matched && entry.Type().IsRegular() {
+					add(path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		case err != nil && strings.ContainsAny(argument, "*?["):
+			matches, err := filepath.Glob(argument)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", argument, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%s: no files match", argument)
+			}
+			for _, match := range matches {
+				add(match)
+			}
+		default:
+			files = append(files, argument)
+		}
+	}
+	return files, nil
+}
+
+// isExcluded reports whether the name or the path of filename matches one
+// of the patterns.
+func isExcluded(filename string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, filepath.Base(filename)); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, filename); matched {
+			return true
+		}
+	}
+	return false
+}
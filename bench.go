@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"text/tabwriter"
+	"time"
+)
+
+// benchVariant is a way of reading log files that bench compare measures,
+// with the flags that select it.
+type benchVariant struct {
+	name    string
+	flags   string
+	options scanOptions
+}
+
+// benchVariants are the parsers and read paths compared by bench compare.
+// The second one is the default, measured by bench.
+var benchVariants = []benchVariant{
+	{"regexp", "-parser regexp", scanOptions{parse: newLogPatterns().parse}},
+	{"fields", "-parser fields", scanOptions{parse: parseFields}},
+	{"mmap", "-parser fields -mmap", scanOptions{parse: parseFields, mmap: true}},
+}
+
+// benchResult contains the measurements of a single variant.
+type benchResult struct {
+	lines      int64
+	bytes      int64
+	duration   time.Duration
+	mallocs    uint64
+	allocBytes uint64
+	peakRSS    int64
+}
+
+// runBench runs the bench command. "bench file" measures the default
+// parser, "bench compare file" every variant. Every variant runs in a
+// child process, so their peak memory use can be measured separately, and
+// the results are printed as a table. It returns the exit status.
+func runBench(args []string) int {
+	switch {
+	case len(args) == 1 && args[0] != "compare" && args[0] != "run":
+		return benchCompare(args[0], benchVariants[1:2])
+	case len(args) == 2 && args[0] == "compare":
+		return benchCompare(args[1], benchVariants)
+	case len(args) == 3 && args[0] == "run":
+		return benchRun(args[1], args[2])
+	}
+	fmt.Fprintln(os.Stderr, "Usage: ufwLogReader bench [compare] file")
+	return exitUsage
+}
+
+// benchCompare measures the variants reading filename and prints the
+// comparison table.
+func benchCompare(filename string, variants []benchVariant) int {
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(writer, "Variant\tFlags\tLines/s\tMB/s\tAllocations\tAllocated\tPeak RSS\n")
+	for _, variant := range variants {
+		command := exec.Command(executable, "bench", "run", variant.name, filename)
+		var output bytes.Buffer
+		command.Stdout = &output
+		command.Stderr = os.Stderr
+		if err := command.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", variant.name, err)
+			return exitUnreadable
+		}
+
+		var result benchResult
+		var nanoseconds int64
+		if _, err := fmt.Sscan(output.String(), &result.lines, &result.bytes, &nanoseconds, &result.mallocs, &result.allocBytes); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", variant.name, err)
+			return exitUnreadable
+		}
+		result.duration = time.Duration(nanoseconds)
+		result.peakRSS = peakRSS(command.ProcessState)
+
+		seconds := result.duration.Seconds()
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%.1f\t%s\t%s\t%s\n", variant.name, variant.flags,
+			formatCount(int(float64(result.lines)/seconds), true), float64(result.bytes)/seconds/1e6,
+			formatCount(int(result.mallocs), true), formatBytes(int64(result.allocBytes)), formatBytes(result.peakRSS))
+	}
+	writer.Flush()
+	return exitSuccess
+}
+
+// benchRun reads filename with the variant called name and prints the
+// amount of lines and bytes read, the duration in nanoseconds and the
+// amount and size of the allocations, for benchCompare.
+func benchRun(name string, filename string) int {
+	var options scanOptions
+	found := false
+	for _, variant := range benchVariants {
+		if variant.name == name {
+			options, found = variant.options, true
+		}
+	}
+	if !found {
+		fmt.Fprintf(os.Stderr, "unknown variant %q\n", name)
+		return exitUsage
+	}
+
+	options.concurrency = 1
+	options.parsers = runtime.NumCPU()
+	options.maxLineBytes = defaultMaxLineBytes
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	started := time.Now()
+	failures := scanFiles([]string{filename}, newIPPortMapMap(), options)
+	duration := time.Since(started)
+	runtime.ReadMemStats(&after)
+	if len(failures) > 0 {
+		fmt.Fprintln(os.Stderr, failures[0].err)
+		return exitUnreadable
+	}
+
+	// Count the lines afterwards, counting them while scanning would
+	// slow the variants down.
+	lines, size, err := countLines(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUnreadable
+	}
+	fmt.Println(lines, size, duration.Nanoseconds(), after.Mallocs-before.Mallocs, after.TotalAlloc-before.TotalAlloc)
+	return exitSuccess
+}
+
+// countLines returns the amount of lines and bytes of filename.
+func countLines(filename string) (int, int64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	lines := 0
+	var size int64
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := file.Read(buffer)
+		lines += bytes.Count(buffer[:n], []byte{'\n'})
+		size += int64(n)
+		if err == io.EOF {
+			return lines, size, nil
+		}
+		if err != nil {
+			return lines, size, err
+		}
+	}
+}
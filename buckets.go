@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// timeBucket identifies the requests of one IP address within the bucket
+// starting at start. ipAddress is empty when buckets are counted globally.
+type timeBucket struct {
+	start     time.Time
+	ipAddress string
+}
+
+// bucketCount is a single row of the time-series output.
+type bucketCount struct {
+	Start     time.Time `json:"start"`
+	IPAddress string    `json:"ip,omitempty"`
+	Count     int       `json:"count"`
+}
+
+// sortedBuckets returns the bucket counts ordered by start time and IP
+// address.
+func sortedBuckets(buckets map[timeBucket]int) []bucketCount {
+	counts := make([]bucketCount, 0, len(buckets))
+	for bucket, count := range buckets {
+		counts = append(counts, bucketCount{bucket.start, bucket.ipAddress, count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if !counts[i].Start.Equal(counts[j].Start) {
+			return counts[i].Start.Before(counts[j].Start)
+		}
+		return counts[i].IPAddress < counts[j].IPAddress
+	})
+	return counts
+}
+
+// writeBuckets writes the bucket counts as CSV, JSON or InfluxDB line
+// protocol to w.
+func writeBuckets(w io.Writer, buckets map[timeBucket]int, perIP bool, format string) error {
+	counts := sortedBuckets(buckets)
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(counts)
+	case "csv":
+		writer := csv.NewWriter(w)
+		header := []string{"start", "count"}
+		if perIP {
+			header = []string{"start", "ip", "count"}
+		}
+		writer.Write(header)
+		for _, count := range counts {
+			record := []string{count.Start.Format(time.RFC3339), strconv.Itoa(count.Count)}
+			if perIP {
+				record = []string{count.Start.Format(time.RFC3339), count.IPAddress, strconv.Itoa(count.Count)}
+			}
+			writer.Write(record)
+		}
+		writer.Flush()
+		return writer.Error()
+	case "influx":
+		for _, count := range counts {
+			tags := ""
+			if perIP {
+				tags = ",src=" + influxEscaper.Replace(count.IPAddress)
+			}
+			if _, err := fmt.Fprintf(w, "ufw_requests%s count=%di %d\n", tags, count.Count, count.Start.UnixNano()); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown bucket format %q, use csv, json or influx", format)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Width in characters of the longest bar in a chart.
+const chartWidth = 40
+
+// sortedByAmount returns the keys of amounts sorted by their amount, highest
+// first. If limit is larger than zero at most limit keys are returned.
+func sortedByAmount(amounts map[string]int, limit int) []string {
+	keys := make([]string, 0, len(amounts))
+	for key := range amounts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if amounts[keys[i]] != amounts[keys[j]] {
+			return amounts[keys[i]] > amounts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+// printBarChart prints the top entries of amounts with a proportional bar
+// next to every amount, humanized when human is set.
+func printBarChart(title string, amounts map[string]int, limit int, human bool) {
+	keys := sortedByAmount(amounts, limit)
+	if len(keys) == 0 {
+		return
+	}
+
+	width := 0
+	for _, key := range keys {
+		if len(key) > width {
+			width = len(key)
+		}
+	}
+
+	highest := amounts[keys[0]]
+	fmt.Printf("\n%s\n\n", title)
+	for _, key := range keys {
+		amount := amounts[key]
+		length := amount * chartWidth / highest
+		if length == 0 && amount > 0 {
+			length = 1
+		}
+		fmt.Printf("%-*s %8s %s\n", width, key, formatCount(amount, human), strings.Repeat("█", length))
+	}
+}
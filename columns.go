@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default columns of the CSV report.
+const defaultColumns = "ip,count,ports"
+
+// reportColumns renders the value of a column for an IP address.
+var reportColumns = map[string]func(report *report, ipAddress string) string{
+	"ip": func(report *report, ipAddress string) string {
+		return ipAddress
+	},
+	"label": func(report *report, ipAddress string) string {
+		return report.labels.lookup(ipAddress)
+	},
+	"count": func(report *report, ipAddress string) string {
+		return strconv.Itoa(report.ipPortMapMap.ipPortMapMap[ipAddress].amountOfRequests)
+	},
+	"retransmissions": func(report *report, ipAddress string) string {
+		return strconv.Itoa(report.ipPortMapMap.ipPortMapMap[ipAddress].retransmissions)
+	},
+	"hosts": func(report *report, ipAddress string) string {
+		return strconv.Itoa(uniqueHosts(report.ipPortMapMap.ipPortMapMap[ipAddress]))
+	},
+	"ports": func(report *report, ipAddress string) string {
+		return amountList(report.ipPortMapMap.ipPortMapMap[ipAddress].portCounts(), 5)
+	},
+	"destinations": func(report *report, ipAddress string) string {
+		return amountList(report.ipPortMapMap.ipPortMapMap[ipAddress].destinationCounts(), 5)
+	},
+	"flags": func(report *report, ipAddress string) string {
+		return amountList(report.ipPortMapMap.ipPortMapMap[ipAddress].flagCounts(), 0)
+	},
+	"tags": func(report *report, ipAddress string) string {
+		return amountList(report.ipPortMapMap.ipPortMapMap[ipAddress].tags, 0)
+	},
+	"first_seen": func(report *report, ipAddress string) string {
+		return formatSeen(report.ipPortMapMap.ipPortMapMap[ipAddress].firstSeen)
+	},
+	"last_seen": func(report *report, ipAddress string) string {
+		return formatSeen(report.ipPortMapMap.ipPortMapMap[ipAddress].lastSeen)
+	},
+	"greynoise": func(report *report, ipAddress string) string {
+		return report.greyNoise[ipAddress]
+	},
+	"network": func(report *report, ipAddress string) string {
+		return report.rdapNetworks[ipAddress].name
+	},
+	"country": func(report *report, ipAddress string) string {
+		return report.rdapNetworks[ipAddress].country
+	},
+	"abuse": func(report *report, ipAddress string) string {
+		return report.rdapNetworks[ipAddress].abuseEmail
+	},
+	"probe": func(report *report, ipAddress string) string {
+		return report.probeResults[ipAddress]
+	},
+	"dnsbl": func(report *report, ipAddress string) string {
+		return strings.Join(report.dnsblListings[ipAddress], " ")
+	},
+	"domains": func(report *report, ipAddress string) string {
+		return strings.Join(report.pdnsDomains[ipAddress], " ")
+	},
+}
+
+// numericColumns are the columns with a number, which are aligned to the
+// right in the table of printColumns.
+var numericColumns = map[string]bool{"count": true, "retransmissions": true, "hosts": true}
+
+// enrichmentColumns returns the columns of the enabled enrichments, which
+// are added to the default columns of the CSV report.
+func enrichmentColumns(dnsbl bool, greyNoise bool, whois bool, probe bool, pdns bool) []string {
+	var columns []string
+	if greyNoise {
+		columns = append(columns, "greynoise")
+	}
+	if whois {
+		columns = append(columns, "network", "country", "abuse")
+	}
+	if probe {
+		columns = append(columns, "probe")
+	}
+	if dnsbl {
+		columns = append(columns, "dnsbl")
+	}
+	if pdns {
+		columns = append(columns, "domains")
+	}
+	return columns
+}
+
+// parseColumns parses a comma separated list of column names.
+func parseColumns(list string) ([]string, error) {
+	columns := splitList(list)
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns given")
+	}
+	for _, column := range columns {
+		if _, ok := reportColumns[column]; !ok {
+			return nil, fmt.Errorf("unknown column %q, available columns: %s", column, strings.Join(columnNames(), ","))
+		}
+	}
+	return columns, nil
+}
+
+// columnNames returns the names of all columns in alphabetical order.
+func columnNames() []string {
+	names := make([]string, 0, len(reportColumns))
+	for name := range reportColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// rows returns a row with the columns of every reported IP address.
+func (report *report) rows(columns []string) [][]string {
+	var rows [][]string
+	for _, ipAddress := range report.reportedIPAddresses() {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = reportColumns[column](report, ipAddress)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// printColumns prints a table with the columns of every reported IP
+// address.
+func (report *report) printColumns(w io.Writer, columns []string) {
+	var rightAligned []int
+	for i, column := range columns {
+		if numericColumns[column] {
+			rightAligned = append(rightAligned, i)
+		}
+	}
+	table := newTextTable("", columns, rightAligned...)
+	for _, row := range report.rows(columns) {
+		table.add(row...)
+	}
+	table.write(w)
+}
+
+// writeCSV writes the columns of every reported IP address as CSV.
+func (report *report) writeCSV(w io.Writer, columns []string) error {
+	writer := csv.NewWriter(w)
+	writer.Write(columns)
+	writer.WriteAll(report.rows(columns))
+	return writer.Error()
+}
+
+// amountList formats the limit highest amounts as "key:amount" separated
+// by spaces.
+func amountList(amounts map[string]int, limit int) string {
+	var list []string
+	for _, key := range sortedByAmount(amounts, limit) {
+		list = append(list, fmt.Sprintf("%s:%d", key, amounts[key]))
+	}
+	return strings.Join(list, " ")
+}
+
+// formatSeen formats a first or last seen timestamp, or returns an empty
+// string when it is unknown.
+func formatSeen(timestamp time.Time) string {
+	if timestamp.IsZero() {
+		return ""
+	}
+	return timestamp.Format(time.RFC3339)
+}
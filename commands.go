@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a subcommand of ufwLogReader.
+type command struct {
+	name        string
+	usage       string
+	description string
+}
+
+// commands are the subcommands of ufwLogReader. Arguments that don't start
+// with a command name run the report command.
+var commands = []command{
+	{"report", "report [flags] file...", "print a report of the requests in the log files (default)"},
+	{"follow", "follow [flags] file...", "follow the log files and write a summary every -interval"},
+	{"export", "export [flags] file...", "write a CSV row with the -columns of every IP address"},
+	{"diff", "diff [-top n] old new", "compare two log files or CSV exports: new and disappeared IP addresses and port changes"},
+	{"rules", "rules check config.json | rules test config.json file... | rules presets", "validate the alert rules of a configuration file, replay logs against them or list the presets"},
+	{"gen", "gen [flags]", "write a synthetic ufw log for testing, benchmarking and demos"},
+	{"bench", "bench [compare] file", "measure the speed and memory use of the parser, or compare the parsers and read paths"},
+	{"selftest", "selftest [-write dir]", "compare the reports of the embedded logs with the golden files"},
+	{"completion", "completion bash|zsh", "print a shell completion script"},
+	{"help", "help [command]", "show the help of a command"},
+}
+
+// findCommand returns the command called name.
+func findCommand(name string) (command, bool) {
+	for _, command := range commands {
+		if command.name == name {
+			return command, true
+		}
+	}
+	return command{}, false
+}
+
+// splitCommand splits the command line arguments into the command and its
+// arguments. Without a command name the arguments belong to report, so
+// "ufwLogReader file" keeps working.
+func splitCommand(args []string) (string, []string) {
+	if len(args) > 0 {
+		if _, ok := findCommand(args[0]); ok {
+			return args[0], args[1:]
+		}
+	}
+	return "report", args
+}
+
+// printUsage writes the commands and the flags of the report commands to w.
+func printUsage(w io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: ufwLogReader [command] [flags] file...\n\nCommands:\n")
+	for _, command := range commands {
+		fmt.Fprintf(w, "  %-26s%s\n", command.usage, command.description)
+	}
+	fmt.Fprintf(w, "\nFlags of report, follow and export:\n")
+	flags.SetOutput(w)
+	flags.PrintDefaults()
+}
+
+// printCommandHelp writes the help of the command called name to w, the
+// report commands include their flags.
+func printCommandHelp(w io.Writer, name string, flags *flag.FlagSet) error {
+	command, ok := findCommand(name)
+	if !ok {
+		return fmt.Errorf("unknown command %q", name)
+	}
+	fmt.Fprintf(w, "Usage: ufwLogReader %s\n\n%s.\n", command.usage, strings.ToUpper(command.description[:1])+command.description[1:])
+	switch name {
+	case "report", "follow", "export":
+		fmt.Fprintf(w, "\nFlags:\n")
+		flags.SetOutput(w)
+		flags.PrintDefaults()
+	}
+	return nil
+}
+
+// printCompletion writes a completion script for shell to w, completing the
+// command names, the flags and file names.
+func printCompletion(w io.Writer, shell string, flags *flag.FlagSet) error {
+	var names []string
+	for _, command := range commands {
+		names = append(names, command.name)
+	}
+	var flagNames []string
+	flags.VisitAll(func(f *flag.Flag) {
+		flagNames = append(flagNames, "-"+f.Name)
+	})
+	sort.Strings(flagNames)
+
+	switch shell {
+	case "bash":
+	case "zsh":
+		fmt.Fprintf(w, "autoload -U +X bashcompinit && bashcompinit\n")
+	default:
+		return fmt.Errorf("unknown shell %q, use bash or zsh", shell)
+	}
+	fmt.Fprintf(w, `_ufwLogReader() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	case "$cur" in
+	-*)
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+		;;
+	*)
+		COMPREPLY=($(compgen -f -- "$cur"))
+		if [ "$COMP_CWORD" -eq 1 ]; then
+			COMPREPLY+=($(compgen -W "%s" -- "$cur"))
+		fi
+		;;
+	esac
+}
+complete -o filenames -F _ufwLogReader ufwLogReader
+`, strings.Join(flagNames, " "), strings.Join(names, " "))
+	return nil
+}
+
+// runRules runs the rules command. "rules check config.json" validates the
+// alert rules, "rules test config.json file..." replays the log files
+// against them and prints the alerts that would have fired, without
+// running their actions. "rules presets" lists the alert presets. It
+// returns the exit status.
+func runRules(args []string) int {
+	switch {
+	case len(args) == 1 && args[0] == "presets":
+		printPresets(os.Stdout)
+		return exitSuccess
+	case len(args) == 2 && args[0] == "check":
+	case len(args) >= 3 && args[0] == "test":
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: ufwLogReader rules check config.json")
+		fmt.Fprintln(os.Stderr, "       ufwLogReader rules test config.json file...")
+		fmt.Fprintln(os.Stderr, "       ufwLogReader rules presets")
+		return exitUsage
+	}
+	configuration, err := loadConfig(args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if args[0] == "check" {
+		fmt.Printf("%s: %d alert rules are valid\n", args[1], len(configuration.Alerts))
+		return exitSuccess
+	}
+
+	ipPortMapMap := newIPPortMapMap()
+	ipPortMapMap.alerts = newAlertEngine(configuration.Alerts, true)
+	for _, filename := range args[2:] {
+		file, err := openLog(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUnreadable
+		}
+		_, err = scanFile(file, ipPortMapMap, parseFields, defaultMaxLineBytes)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			return exitUnreadable
+		}
+	}
+
+	fired := ipPortMapMap.alerts.firedAlerts()
+	for _, event := range fired {
+		fmt.Println(formatAlertEvent(event))
+	}
+	perRule := make(map[string]int)
+	for _, event := range fired {
+		perRule[event.Rule]++
+	}
+	fmt.Printf("%d alerts would have fired", len(fired))
+	for i, rule := range configuration.Alerts {
+		separator := ", "
+		if i == 0 {
+			separator = ": "
+		}
+		fmt.Printf("%s%s %d", separator, rule.Name, perRule[rule.Name])
+	}
+	fmt.Println()
+	return exitSuccess
+}
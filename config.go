@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// config is the optional JSON configuration file passed with -config.
+type config struct {
+	Alerts []alertRule            `json:"alerts"`
+	Tags   []tagRule              `json:"tags"`
+	Sinks  map[string]*sinkConfig `json:"sinks"`
+}
+
+// loadConfig reads and validates a configuration file.
+func loadConfig(filename string) (*config, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	configuration := new(config)
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(configuration); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	for i := range configuration.Alerts {
+		if err := configuration.Alerts[i].compile(); err != nil {
+			return nil, fmt.Errorf("%s: alert %d: %v", filename, i+1, err)
+		}
+	}
+	for i := range configuration.Tags {
+		if err := configuration.Tags[i].compile(); err != nil {
+			return nil, fmt.Errorf("%s: tag %d: %v", filename, i+1, err)
+		}
+	}
+	for name, sinkConfig := range configuration.Sinks {
+		if !sinkNames[name] {
+			return nil, fmt.Errorf("%s: unknown sink %q", filename, name)
+		}
+		if sinkConfig == nil {
+			continue
+		}
+		if err := sinkConfig.compile(); err != nil {
+			return nil, fmt.Errorf("%s: sink %s: %v", filename, name, err)
+		}
+	}
+	return configuration, nil
+}
+
+// sinkRedaction returns the redaction of the sink called name, or nil when
+// the sink has no redaction rules.
+func (configuration *config) sinkRedaction(name string) *redaction {
+	if configuration == nil || configuration.Sinks[name] == nil {
+		return nil
+	}
+	return configuration.Sinks[name].redaction
+}
+
+// routedSink returns sink, only writing the events with one of the tags of
+// the sink called name when they are configured.
+func (configuration *config) routedSink(name string, sink eventSink) eventSink {
+	if configuration == nil || configuration.Sinks[name] == nil || len(configuration.Sinks[name].Tags) == 0 {
+		return sink
+	}
+	return &taggedSink{eventSink: sink, tags: configuration.Sinks[name].Tags}
+}
@@ -0,0 +1,85 @@
+package main
+
+// internTable assigns compact IDs to the keys counted per IP address: the
+// ports, destination addresses and TCP flags. Every key is stored once,
+// however many IP addresses it is counted for, and the counts of an IP
+// address only hold its 4 byte ID.
+type internTable struct {
+	ids  map[string]uint32
+	keys []string
+}
+
+// newInternTable returns an empty internTable.
+func newInternTable() *internTable {
+	return &internTable{ids: make(map[string]uint32)}
+}
+
+// id returns the ID of key, assigning the next one to a new key.
+func (table *internTable) id(key string) uint32 {
+	if id, ok := table.ids[key]; ok {
+		return id
+	}
+	id := uint32(len(table.keys))
+	table.ids[key] = id
+	table.keys = append(table.keys, key)
+	return id
+}
+
+// keyCount is the count of the key with an ID of an internTable.
+type keyCount struct {
+	id    uint32
+	count int
+}
+
+// countList counts the requests per key of a single IP address, sorted by
+// the ID of the key. Most IP addresses only send requests to a few ports
+// and destinations, and a short slice takes a fraction of the memory of a
+// map. Keys are found by binary search, so port scanners sending requests
+// to thousands of ports are counted quickly as well.
+type countList []keyCount
+
+// add adds amount to the count of the key with id.
+func (list *countList) add(id uint32, amount int) {
+	counts := *list
+	low, high := 0, len(counts)
+	for low < high {
+		middle := int(uint(low+high) >> 1)
+		if counts[middle].id < id {
+			low = middle + 1
+		} else {
+			high = middle
+		}
+	}
+	if low < len(counts) && counts[low].id == id {
+		counts[low].count += amount
+		return
+	}
+	counts = append(counts, keyCount{})
+	copy(counts[low+1:], counts[low:])
+	counts[low] = keyCount{id: id, count: amount}
+	*list = counts
+}
+
+// merge adds the counts of other to list.
+func (list *countList) merge(other countList) {
+	for _, keyCount := range other {
+		list.add(keyCount.id, keyCount.count)
+	}
+}
+
+// amounts returns the counts by key, for the reports.
+func (list countList) amounts(table *internTable) map[string]int {
+	amounts := make(map[string]int, len(list))
+	for _, keyCount := range list {
+		amounts[table.keys[keyCount.id]] = keyCount.count
+	}
+	return amounts
+}
+
+// addCount adds amount to the count of key, making counts on first use.
+func addCount[K comparable](counts *map[K]int, key K, amount int) {
+	if *counts == nil {
+		*counts = make(map[K]int)
+	}
+	(*counts)[key] += amount
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCountList(t *testing.T) {
+	keys := newInternTable()
+	var ports countList
+	for _, port := range []string{"3389", "22", "22", "23", "3389", "22"} {
+		ports.add(keys.id(port), 1)
+	}
+	want := map[string]int{"22": 3, "23": 1, "3389": 2}
+	if got := ports.amounts(keys); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for i := 1; i < len(ports); i++ {
+		if ports[i-1].id >= ports[i].id {
+			t.Fatalf("counts are not sorted by id: %v", ports)
+		}
+	}
+
+	var other countList
+	other.add(keys.id("445"), 4)
+	other.add(keys.id("22"), 1)
+	other.merge(ports)
+	want = map[string]int{"22": 4, "23": 1, "445": 4, "3389": 2}
+	if got := other.amounts(keys); !reflect.DeepEqual(got, want) {
+		t.Errorf("merged: got %v, want %v", got, want)
+	}
+}
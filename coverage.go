@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// coverageGap is a range of days without a single logged request between
+// the first and the last day of the logs, most likely a missing or
+// unreadable file rather than a quiet period.
+type coverageGap struct {
+	first time.Time
+	last  time.Time
+}
+
+// String returns the gap as "no data for Jun 3–4".
+func (gap coverageGap) String() string {
+	switch {
+	case gap.first.Equal(gap.last):
+		return "no data for " + gap.first.Format("Jan 2")
+	case gap.first.Month() == gap.last.Month() && gap.first.Year() == gap.last.Year():
+		return "no data for " + gap.first.Format("Jan 2") + "–" + gap.last.Format("2")
+	}
+	return "no data for " + gap.first.Format("Jan 2") + "–" + gap.last.Format("Jan 2")
+}
+
+// coverageGaps returns the days without requests in hourlyRequests between
+// the first and the last day with requests, as ranges of consecutive days.
+func coverageGaps(hourlyRequests map[time.Time]int) []coverageGap {
+	days := make(map[time.Time]bool)
+	for hour := range hourlyRequests {
+		days[startOfDay(hour)] = true
+	}
+	if len(days) < 2 {
+		return nil
+	}
+	sorted := make([]time.Time, 0, len(days))
+	for day := range days {
+		sorted = append(sorted, day)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	var gaps []coverageGap
+	for i := 1; i < len(sorted); i++ {
+		next := sorted[i-1].AddDate(0, 0, 1)
+		if next.Before(sorted[i]) {
+			gaps = append(gaps, coverageGap{first: next, last: sorted[i].AddDate(0, 0, -1)})
+		}
+	}
+	return gaps
+}
+
+// startOfDay returns midnight of the day of timestamp in its location.
+func startOfDay(timestamp time.Time) time.Time {
+	return time.Date(timestamp.Year(), timestamp.Month(), timestamp.Day(), 0, 0, 0, 0, timestamp.Location())
+}
+
+// joinGaps returns the gaps separated by commas.
+func joinGaps(gaps []coverageGap) string {
+	descriptions := make([]string, len(gaps))
+	for i, gap := range gaps {
+		descriptions[i] = gap.String()
+	}
+	return strings.Join(descriptions, ", ")
+}
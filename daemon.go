@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// How often followed log files are checked for new lines.
+const followPollInterval = time.Second
+
+// daemonOptions configures runDaemon. Lines longer than maxLineBytes are
+// skipped. When metrics is set its counters are emitted every metrics
+// interval. When stale is set a file that receives no lines for that long
+// is reported. When live is set it is drawn every second instead of
+// writing the summaries to stdout.
+type daemonOptions struct {
+	interval time.Duration
+	output   string
+	reset    bool
+	labels   *labelTable
+	services serviceTable
+	notifier *webhookNotifier
+	metrics  *metricsSink
+	stale    time.Duration
+	live     *liveView
+
+	maxLineBytes int
+}
+
+// runDaemon follows the log files and writes a fresh summary of the requests
+// to the output every interval. The output is stdout, syslog or the name
+// of a file the summaries are appended to. With reset every summary only
+// covers the requests since the previous one, otherwise the counts are
+// carried over. runDaemon only returns on an error.
+func runDaemon(filenames []string, ipPortMapMap *ipPortMapMap, parse lineParser, options daemonOptions) error {
+	writeSummary, err := summaryWriter(options.output)
+	if err != nil {
+		return err
+	}
+	if options.live != nil && (options.output == "" || options.output == "stdout") {
+		// The summaries would scroll the live view away.
+		writeSummary = func(summary string) error { return nil }
+	}
+
+	lines := make(chan followedLine)
+	errs := make(chan error)
+	for _, filename := range filenames {
+		go func(filename string) {
+			errs <- followFile(filename, lines)
+		}(filename)
+	}
+
+	// The lines are logged now, a year starts when they jump back.
+	years := newYearInference(time.Now())
+	var monitor *staleMonitor
+	if options.stale > 0 {
+		monitor = newStaleMonitor(filenames, options.stale, time.Now())
+	}
+	summaries := time.NewTicker(options.interval)
+	defer summaries.Stop()
+	notifications := time.NewTicker(followPollInterval)
+	defer notifications.Stop()
+	var emits <-chan time.Time
+	if options.metrics != nil {
+		metrics := time.NewTicker(options.metrics.interval)
+		defer metrics.Stop()
+		emits = metrics.C
+	}
+
+	for {
+		select {
+		case line := <-lines:
+			if monitor != nil {
+				if message := monitor.seen(line.filename, time.Now()); message != "" {
+					if err := reportStale(message, writeSummary, options.notifier); err != nil {
+						return err
+					}
+				}
+			}
+			if len(line.text) > options.maxLineBytes {
+				infof("skipped a line of %d bytes, see -max-line-bytes\n", len(line.text))
+				continue
+			}
+			scanLine([]byte(line.text), ipPortMapMap, parse, years)
+		case now := <-notifications.C:
+			if options.live != nil {
+				options.live.draw(os.Stdout, now, options.services)
+			}
+			if monitor != nil {
+				for _, message := range monitor.check(now) {
+					if err := reportStale(message, writeSummary, options.notifier); err != nil {
+						return err
+					}
+				}
+			}
+			if options.notifier != nil {
+				ipPortMapMap.RLock()
+				messages := options.notifier.crossedThresholds(ipPortMapMap, options.labels)
+				ipPortMapMap.RUnlock()
+				for _, err := range options.notifier.notify(messages) {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}
+			for _, err := range flushSinks(ipPortMapMap.sinks) {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		case now := <-emits:
+			if err := options.metrics.emit(now); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		case now := <-summaries.C:
+			var summary bytes.Buffer
+			fmt.Fprintf(&summary, "ufw summary at %s\n\n", now.Format(time.RFC3339))
+			ipPortMapMap.RLock()
+			report := &report{ipPortMapMap: ipPortMapMap, ipAddresses: ipPortMapMap.topIPAddresses(0), labels: options.labels, services: options.services}
+			report.printText(&summary)
+			ipPortMapMap.RUnlock()
+			if err := writeSummary(summary.String()); err != nil {
+				return err
+			}
+			if options.reset {
+				ipPortMapMap.reset()
+				if options.notifier != nil {
+					options.notifier.reset()
+				}
+			}
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+// reportStale writes a message about a stale log file to the output and
+// posts it to the webhook, if any. Webhook errors are printed, it returns
+// the error of writing the output.
+func reportStale(message string, writeSummary func(summary string) error, notifier *webhookNotifier) error {
+	if notifier != nil {
+		if err := notifier.postText(message); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	return writeSummary("ufw log health at " + time.Now().Format(time.RFC3339) + ": " + message)
+}
+
+// summaryWriter returns a function writing a summary to output.
+func summaryWriter(output string) (func(summary string) error, error) {
+	switch output {
+	case "", "stdout":
+		return func(summary string) error {
+			_, err := fmt.Println(summary)
+			return err
+		}, nil
+	case "syslog":
+		return syslogWriter()
+	default:
+		return func(summary string) error {
+			file, err := os.OpenFile(output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(file, summary); err != nil {
+				file.Close()
+				return err
+			}
+			return file.Close()
+		}, nil
+	}
+}
+
+// followedLine is a line appended to a followed file.
+type followedLine struct {
+	filename string
+	text     string
+}
+
+// followFile sends every line appended to filename to lines, starting at
+// its current end. When the file is rotated or truncated it is reopened
+// and read from the start.
+func followFile(filename string, lines chan<- followedLine) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer func() { file.Close() }()
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	var partial string
+	for {
+		line, err := reader.ReadString('\n')
+		if err == nil {
+			lines <- followedLine{filename: filename, text: partial + strings.TrimRight(line, "\r\n")}
+			partial = ""
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+		partial += line
+		time.Sleep(followPollInterval)
+
+		rotated, err := isRotated(file, filename)
+		if err != nil {
+			continue
+		}
+		if rotated {
+			newFile, err := os.Open(filename)
+			if err != nil {
+				continue
+			}
+			file.Close()
+			file = newFile
+			reader.Reset(file)
+			partial = ""
+		}
+	}
+}
+
+// isRotated reports whether filename no longer refers to file, or file
+// was truncated below the current read position.
+func isRotated(file *os.File, filename string) (bool, error) {
+	current, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	latest, err := os.Stat(filename)
+	if err != nil {
+		return false, err
+	}
+	if !os.SameFile(current, latest) {
+		return true, nil
+	}
+	position, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, err
+	}
+	return latest.Size() < position, nil
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package main
+
+import (
+	"log/syslog"
+	"strings"
+)
+
+// syslogWriter returns a function writing every line of a summary to the
+// local syslog daemon.
+func syslogWriter() (func(summary string) error, error) {
+	writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "ufwLogReader")
+	if err != nil {
+		return nil, err
+	}
+	return func(summary string) error {
+		for _, line := range strings.Split(strings.TrimRight(summary, "\n"), "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if err := writer.Info(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
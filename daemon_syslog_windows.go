@@ -0,0 +1,9 @@
+package main
+
+import "errors"
+
+// syslogWriter returns an error, Windows has no syslog daemon to write the
+// summaries to.
+func syslogWriter() (func(summary string) error, error) {
+	return nil, errors.New("-daemon-output syslog is not supported on Windows")
+}
@@ -0,0 +1,42 @@
+package main
+
+// dedupKey identifies the requests that are collapsed by -dedup.
+type dedupKey struct {
+	source   string
+	port     string
+	protocol string
+}
+
+// isRetransmission reports whether entry repeats a request of the same
+// source, port and protocol counted less than dedupWindow before it. Only
+// counted requests start a window, so a long burst is counted once every
+// window. The caller must hold the lock.
+func (ipPortMapMap *ipPortMapMap) isRetransmission(entry *logEntry) bool {
+	if ipPortMapMap.dedupWindow <= 0 || !entry.hasTimestamp {
+		return false
+	}
+	key := dedupKey{source: entry.source, port: entry.port, protocol: entry.protocol}
+	if counted, ok := ipPortMapMap.dedupCounted[key]; ok {
+		if elapsed := entry.timestamp.Sub(counted); elapsed >= 0 && elapsed < ipPortMapMap.dedupWindow {
+			return true
+		}
+	}
+	ipPortMapMap.dedupCounted[key] = entry.timestamp
+	if entry.timestamp.After(ipPortMapMap.dedupLatest) {
+		ipPortMapMap.dedupLatest = entry.timestamp
+	}
+	if len(ipPortMapMap.dedupCounted) >= 2*ipPortMapMap.dedupPruned+1024 {
+		ipPortMapMap.pruneDedup()
+	}
+	return false
+}
+
+// pruneDedup forgets the requests whose window has passed.
+func (ipPortMapMap *ipPortMapMap) pruneDedup() {
+	for key, counted := range ipPortMapMap.dedupCounted {
+		if ipPortMapMap.dedupLatest.Sub(counted) >= ipPortMapMap.dedupWindow {
+			delete(ipPortMapMap.dedupCounted, key)
+		}
+	}
+	ipPortMapMap.dedupPruned = len(ipPortMapMap.dedupCounted)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diffSide contains the amount of requests per source IP address and per
+// port of one side of a diff.
+type diffSide struct {
+	ipRequests   map[string]int
+	portRequests map[string]int
+}
+
+// runDiff runs the diff command, comparing an old and a new log file or
+// saved CSV export. It returns the exit status.
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	top := flags.Int("top", 20, "number of rows in every table, 0 for all")
+	services := flags.String("services", "/etc/services", "services `file` used to show the service names of ports")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: ufwLogReader diff [-top n] old new")
+		return exitUsage
+	}
+
+	oldSide, err := loadDiffSide(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUnreadable
+	}
+	newSide, err := loadDiffSide(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUnreadable
+	}
+	printDiff(os.Stdout, oldSide, newSide, loadServices(*services), *top)
+	return exitSuccess
+}
+
+// loadDiffSide reads a log file, or a CSV export with at least the ip and
+// count columns. The port counts of an export only include the ports in
+// its ports column, the top 5 ports of every IP address.
+func loadDiffSide(filename string) (*diffSide, error) {
+	file, err := openLog(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	side := &diffSide{ipRequests: make(map[string]int), portRequests: make(map[string]int)}
+	reader := bufio.NewReader(file.Reader)
+	file.Reader = reader
+	if header, _ := reader.Peek(3); string(header) == "ip," {
+		if err := side.readExport(reader); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		return side, nil
+	}
+
+	ipPortMapMap := newIPPortMapMap()
+	if _, err := scanFile(file, ipPortMapMap, parseFields, defaultMaxLineBytes); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	for ipAddress, ipPortMapStruct := range ipPortMapMap.ipPortMapMap {
+		side.ipRequests[ipAddress] = ipPortMapStruct.amountOfRequests
+		for portNumber, amount := range ipPortMapStruct.portCounts() {
+			side.portRequests[portNumber] += amount
+		}
+	}
+	return side, nil
+}
+
+// readExport reads a CSV export written by the export command.
+func (side *diffSide) readExport(r io.Reader) error {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	countColumn, ok := columns["count"]
+	if !ok {
+		return fmt.Errorf("no count column")
+	}
+	portsColumn, hasPorts := columns["ports"]
+
+	for _, record := range records[1:] {
+		amount, err := strconv.Atoi(record[countColumn])
+		if err != nil {
+			return fmt.Errorf("invalid count %q", record[countColumn])
+		}
+		side.ipRequests[record[columns["ip"]]] = amount
+		if !hasPorts {
+			continue
+		}
+		for _, portAmount := range strings.Fields(record[portsColumn]) {
+			separator := strings.LastIndex(portAmount, ":")
+			amount, err := strconv.Atoi(portAmount[separator+1:])
+			if separator < 0 || err != nil {
+				return fmt.Errorf("invalid port amount %q", portAmount)
+			}
+			side.portRequests[portAmount[:separator]] += amount
+		}
+	}
+	return nil
+}
+
+// printDiff writes the IP addresses that are new in newSide, the IP
+// addresses that disappeared and the change of the amount of requests per
+// port to w. Every table has at most limit rows when limit is larger than
+// zero.
+func printDiff(w io.Writer, oldSide *diffSide, newSide *diffSide, services serviceTable, limit int) {
+	appeared := make(map[string]int)
+	disappeared := make(map[string]int)
+	for ipAddress, amount := range newSide.ipRequests {
+		if _, ok := oldSide.ipRequests[ipAddress]; !ok {
+			appeared[ipAddress] = amount
+		}
+	}
+	for ipAddress, amount := range oldSide.ipRequests {
+		if _, ok := newSide.ipRequests[ipAddress]; !ok {
+			disappeared[ipAddress] = amount
+		}
+	}
+
+	fmt.Fprintf(w, "New IP addresses: %d\n\n", len(appeared))
+	table := newTextTable("\t", []string{"IP address", "Amount"}, 1)
+	for _, ipAddress := range sortedByAmount(appeared, limit) {
+		table.add(ipAddress, strconv.Itoa(appeared[ipAddress]))
+	}
+	table.write(w)
+
+	fmt.Fprintf(w, "\nDisappeared IP addresses: %d\n\n", len(disappeared))
+	table = newTextTable("\t", []string{"IP address", "Amount"}, 1)
+	for _, ipAddress := range sortedByAmount(disappeared, limit) {
+		table.add(ipAddress, strconv.Itoa(disappeared[ipAddress]))
+	}
+	table.write(w)
+
+	var ports []string
+	for portNumber, amount := range oldSide.portRequests {
+		if newSide.portRequests[portNumber] != amount {
+			ports = append(ports, portNumber)
+		}
+	}
+	for portNumber := range newSide.portRequests {
+		if _, ok := oldSide.portRequests[portNumber]; !ok {
+			ports = append(ports, portNumber)
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		a := absolute(newSide.portRequests[ports[i]] - oldSide.portRequests[ports[i]])
+		b := absolute(newSide.portRequests[ports[j]] - oldSide.portRequests[ports[j]])
+		if a != b {
+			return a > b
+		}
+		return ports[i] < ports[j]
+	})
+	if limit > 0 && len(ports) > limit {
+		ports = ports[:limit]
+	}
+
+	fmt.Fprintf(w, "\nPort changes\n\n")
+	table = newTextTable("\t", []string{"Port Number", "Old", "New", "Change"}, 1, 2, 3)
+	for _, portNumber := range ports {
+		oldAmount, newAmount := oldSide.portRequests[portNumber], newSide.portRequests[portNumber]
+		table.add(services.withService(portNumber), strconv.Itoa(oldAmount), strconv.Itoa(newAmount), fmt.Sprintf("%+d", newAmount-oldAmount))
+	}
+	table.write(w)
+}
+
+// absolute returns the absolute value of n.
+func absolute(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
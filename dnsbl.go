@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of DNSBL queries that run at the same time.
+const dnsblConcurrency = 16
+
+// dnsblQuery is a single lookup of an IP address on one DNSBL zone.
+type dnsblQuery struct {
+	ipAddress string
+	zone      string
+}
+
+// lookupDNSBLs checks every IP address against every zone and returns, per
+// IP address, the zones it is listed on. Queries are batched over a fixed
+// number of goroutines, every query is subject to the budget and timeout.
+func lookupDNSBLs(ipAddresses []string, zones []string, budget *enrichmentBudget, timeout time.Duration) map[string][]string {
+	queries := make(chan dnsblQuery)
+	listings := make(map[string][]string)
+	var mutex sync.Mutex
+	var waitGroup sync.WaitGroup
+
+	for i := 0; i < dnsblConcurrency; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for query := range queries {
+				if !budget.take() {
+					continue
+				}
+				if isListed(query.ipAddress, query.zone, timeout) {
+					mutex.Lock()
+					listings[query.ipAddress] = append(listings[query.ipAddress], query.zone)
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, ipAddress := range ipAddresses {
+		for _, zone := range zones {
+			queries <- dnsblQuery{ipAddress, zone}
+		}
+	}
+	close(queries)
+	waitGroup.Wait()
+
+	for ipAddress := range listings {
+		sort.Strings(listings[ipAddress])
+	}
+	return listings
+}
+
+// isListed reports whether ipAddress has an A record in the DNSBL zone.
+// Lookup failures, including NXDOMAIN and timeouts, count as not listed.
+func isListed(ipAddress string, zone string, timeout time.Duration) bool {
+	name, err := dnsblName(ipAddress, zone)
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupHost(ctx, name)
+	return err == nil && len(addresses) > 0
+}
+
+// dnsblName builds the DNSBL query name: the reversed octets (or nibbles for
+// IPv6) of ipAddress followed by the zone, e.g. 4.3.2.1.zen.spamhaus.org.
+func dnsblName(ipAddress string, zone string) (string, error) {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address %q", ipAddress)
+	}
+
+	var labels []string
+	if ip4 := ip.To4(); ip4 != nil {
+		for i := len(ip4) - 1; i >= 0; i-- {
+			labels = append(labels, fmt.Sprintf("%d", ip4[i]))
+		}
+	} else {
+		for i := len(ip) - 1; i >= 0; i-- {
+			labels = append(labels, fmt.Sprintf("%x", ip[i]&0x0f), fmt.Sprintf("%x", ip[i]>>4))
+		}
+	}
+	return strings.Join(labels, ".") + "." + strings.TrimSuffix(zone, "."), nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dumpBufferBytes is the size of the buffer of a dump, the memory a dump
+// uses no matter how many requests it contains.
+const dumpBufferBytes = 256 * 1024
+
+// dumpHeader are the columns of a CSV dump and the fields of an NDJSON
+// dump.
+var dumpHeader = []string{"timestamp", "action", "in", "source", "destination", "protocol", "port", "flags", "tags"}
+
+// dumpSink streams every request as a CSV row or an NDJSON object to a
+// file through a bounded buffer, so full dumps of huge logs don't have to
+// fit in memory. Files ending in .gz are gzip compressed. When redaction
+// is set the events are redacted before they are written.
+type dumpSink struct {
+	sync.Mutex
+	format     string
+	file       *os.File
+	compressor *gzip.Writer
+	writer     *bufio.Writer
+	csv        *csv.Writer
+	redaction  *redaction
+	err        error
+}
+
+// newDumpSink creates filename and returns a sink writing the requests to
+// it in format, csv or ndjson.
+func newDumpSink(filename string, format string) (*dumpSink, error) {
+	if format != "csv" && format != "ndjson" {
+		return nil, fmt.Errorf("unknown dump format %q, use csv or ndjson", format)
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	sink := &dumpSink{format: format, file: file}
+	var w io.Writer = file
+	if strings.HasSuffix(filename, ".gz") {
+		sink.compressor = gzip.NewWriter(file)
+		w = sink.compressor
+	}
+	sink.writer = bufio.NewWriterSize(w, dumpBufferBytes)
+	if format == "csv" {
+		sink.csv = csv.NewWriter(sink.writer)
+		sink.csv.Write(dumpHeader)
+	}
+	return sink, nil
+}
+
+// writeEvent writes the row or object of event.
+func (sink *dumpSink) writeEvent(event *sinkEvent) {
+	event = sink.redaction.apply(event)
+	var timestamp string
+	if !event.timestamp.IsZero() {
+		timestamp = event.timestamp.Format(time.RFC3339Nano)
+	}
+	record := []string{timestamp, event.action, event.inInterface, event.source, event.destination,
+		event.protocol, event.port, event.flags, strings.Join(event.tags, " ")}
+
+	sink.Lock()
+	defer sink.Unlock()
+	var err error
+	if sink.csv != nil {
+		err = sink.csv.Write(record)
+	} else {
+		object := make(map[string]string, len(record))
+		for i, value := range record {
+			if value != "" {
+				object[dumpHeader[i]] = value
+			}
+		}
+		var line []byte
+		line, err = json.Marshal(object)
+		if err == nil {
+			line = append(line, '\n')
+			_, err = sink.writer.Write(line)
+		}
+	}
+	sink.fail(err)
+}
+
+// flush writes the buffered requests to the file and returns the first
+// error since the previous flush.
+func (sink *dumpSink) flush() error {
+	sink.Lock()
+	defer sink.Unlock()
+	if sink.csv != nil {
+		sink.csv.Flush()
+		sink.fail(sink.csv.Error())
+	}
+	sink.fail(sink.writer.Flush())
+	if sink.compressor != nil {
+		sink.fail(sink.compressor.Flush())
+	}
+	err := sink.err
+	sink.err = nil
+	return err
+}
+
+// close flushes the sink and closes the file.
+func (sink *dumpSink) close() error {
+	err := sink.flush()
+	sink.Lock()
+	defer sink.Unlock()
+	if sink.compressor != nil {
+		sink.fail(sink.compressor.Close())
+	}
+	sink.fail(sink.file.Close())
+	if err == nil {
+		err = sink.err
+	}
+	return err
+}
+
+// fail records err as the error of the sink unless an error is recorded
+// already. The sink has to be locked.
+func (sink *dumpSink) fail(err error) {
+	if err != nil && sink.err == nil {
+		sink.err = fmt.Errorf("dump: %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The amount of documents sent in a single bulk request.
+const elasticsearchBulkSize = 1000
+
+// elasticsearchSink ships events, or with aggregates one document per
+// source IP address, to Elasticsearch using the bulk API. The index name
+// is expanded from a pattern with %Y, %m and %d of the event time. When
+// redaction is set the documents are redacted before they are shipped.
+type elasticsearchSink struct {
+	sync.Mutex
+	url        string
+	index      string
+	aggregates bool
+	redaction  *redaction
+	client     *http.Client
+	bulk       bytes.Buffer
+	documents  int
+	err        error
+}
+
+// elasticsearchTemplate is the index template installed for the indices of
+// the sink. The fields follow the Elastic Common Schema.
+const elasticsearchTemplate = `{
+	"index_patterns": [%q],
+	"template": {
+		"mappings": {
+			"properties": {
+				"@timestamp": {"type": "date"},
+				"source": {"properties": {
+					"ip": {"type": "ip"},
+					"address": {"type": "keyword"},
+					"geo": {"properties": {"country_iso_code": {"type": "keyword"}}}
+				}},
+				"destination": {"properties": {"ip": {"type": "ip"}, "port": {"type": "integer"}}},
+				"network": {"properties": {"transport": {"type": "keyword"}}},
+				"tcp_flags": {"type": "keyword"},
+				"tags": {"type": "keyword"},
+				"event": {"properties": {"count": {"type": "long"}, "start": {"type": "date"}, "end": {"type": "date"}}},
+				"ports": {"type": "keyword"},
+				"ufw": {"properties": {
+					"label": {"type": "keyword"},
+					"greynoise": {"type": "keyword"},
+					"network": {"type": "keyword"},
+					"abuse": {"type": "keyword"},
+					"dnsbl": {"type": "keyword"},
+					"domains": {"type": "keyword"}
+				}}
+			}
+		}
+	}
+}`
+
+// newElasticsearchSink returns a sink shipping to the Elasticsearch server
+// at url, in mode events or aggregates. It installs an index template with
+// the mapping of the fields for the indices matching the index pattern.
+func newElasticsearchSink(url string, index string, mode string) (*elasticsearchSink, error) {
+	if mode != "events" && mode != "aggregates" {
+		return nil, fmt.Errorf("unknown elasticsearch mode %q, use events or aggregates", mode)
+	}
+	sink := &elasticsearchSink{
+		url:        strings.TrimRight(url, "/"),
+		index:      index,
+		aggregates: mode == "aggregates",
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+
+	prefix := index
+	if i := strings.Index(index, "%"); i >= 0 {
+		prefix = index[:i]
+	}
+	name := strings.Trim(prefix, "-_.")
+	if name == "" {
+		name = "ufw"
+	}
+	template := fmt.Sprintf(elasticsearchTemplate, prefix+"*")
+	if err := sink.request("PUT", "/_index_template/"+name, "application/json", []byte(template)); err != nil {
+		return nil, fmt.Errorf("elasticsearch: installing index template: %v", err)
+	}
+	return sink, nil
+}
+
+// indexName expands the index pattern for timestamp.
+func (sink *elasticsearchSink) indexName(timestamp time.Time) string {
+	timestamp = timestamp.UTC()
+	return strings.NewReplacer(
+		"%Y", strconv.Itoa(timestamp.Year()),
+		"%m", fmt.Sprintf("%02d", int(timestamp.Month())),
+		"%d", fmt.Sprintf("%02d", timestamp.Day()),
+	).Replace(sink.index)
+}
+
+// writeEvent adds event to the bulk request, unless the sink ships
+// aggregates.
+func (sink *elasticsearchSink) writeEvent(event *sinkEvent) {
+	if sink.aggregates {
+		return
+	}
+	event = sink.redaction.apply(event)
+	destination := addressFields(event.destination)
+	if port, err := strconv.Atoi(event.port); err == nil {
+		destination["port"] = port
+	}
+	// Lines without a timestamp are indexed at the time they are read.
+	timestamp := event.timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	document := map[string]interface{}{
+		"@timestamp": timestamp.Format(time.RFC3339),
+	}
+	if event.source != "" {
+		document["source"] = addressFields(event.source)
+	}
+	if len(destination) > 0 {
+		document["destination"] = destination
+	}
+	if event.protocol != "" {
+		document["network"] = map[string]string{"transport": strings.ToLower(event.protocol)}
+	}
+	if event.flags != "" {
+		document["tcp_flags"] = strings.Fields(event.flags)
+	}
+	if len(event.tags) > 0 {
+		document["tags"] = event.tags
+	}
+	sink.add(timestamp, document)
+}
+
+// writeAggregates adds a document for every source IP address of the
+// report to the bulk request, when the sink ships aggregates. The documents
+// include the label and enrichments of the address.
+func (sink *elasticsearchSink) writeAggregates(report *report) {
+	if !sink.aggregates {
+		return
+	}
+	for ipAddress, ipPortMapStruct := range report.ipPortMapMap.ipPortMapMap {
+		timestamp := ipPortMapStruct.lastSeen
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+		document := map[string]interface{}{
+			"@timestamp": timestamp.Format(time.RFC3339),
+			"event":      map[string]interface{}{"count": ipPortMapStruct.amountOfRequests},
+		}
+		source := addressFields(sink.redaction.address("source", ipAddress))
+		if len(source) > 0 {
+			document["source"] = source
+		}
+		if !sink.redaction.drops("port") {
+			document["ports"] = sortedByAmount(ipPortMapStruct.portCounts(), 0)
+		}
+		if !ipPortMapStruct.firstSeen.IsZero() {
+			document["event"].(map[string]interface{})["start"] = ipPortMapStruct.firstSeen.Format(time.RFC3339)
+			document["event"].(map[string]interface{})["end"] = ipPortMapStruct.lastSeen.Format(time.RFC3339)
+		}
+		// The label and most enrichments identify the address as well
+		// as the address itself, they are only shipped with it.
+		identifying := !sink.redaction.redacts("source")
+		if country := report.rdapNetworks[ipAddress].country; country != "" && len(source) > 0 && identifying {
+			source["geo"] = map[string]string{"country_iso_code": country}
+		}
+		if enrichment := enrichmentFields(report, ipAddress, identifying); len(enrichment) > 0 {
+			document["ufw"] = enrichment
+		}
+		sink.add(timestamp, document)
+	}
+}
+
+// enrichmentFields returns the non-empty label and enrichments of
+// ipAddress. Lists are sent as arrays. Unless identifying is set only the
+// GreyNoise classification is returned, the label, network, abuse
+// contact, DNSBL listings and domains identify the address.
+func enrichmentFields(report *report, ipAddress string, identifying bool) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if classification := report.greyNoise[ipAddress]; classification != "" {
+		fields["greynoise"] = classification
+	}
+	if !identifying {
+		return fields
+	}
+	for _, column := range []string{"label", "network", "abuse"} {
+		if value := reportColumns[column](report, ipAddress); value != "" {
+			fields[column] = value
+		}
+	}
+	if listings := report.dnsblListings[ipAddress]; len(listings) > 0 {
+		fields["dnsbl"] = listings
+	}
+	if domains := report.pdnsDomains[ipAddress]; len(domains) > 0 {
+		fields["domains"] = domains
+	}
+	return fields
+}
+
+// addressFields returns the ECS fields of an address. Subnets and
+// anonymized addresses are not IP addresses, they only get the address
+// field. An empty address has no fields.
+func addressFields(address string) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if address == "" {
+		return fields
+	}
+	fields["address"] = address
+	if net.ParseIP(address) != nil {
+		fields["ip"] = address
+	}
+	return fields
+}
+
+// add adds document to the bulk request and sends it when it is full.
+func (sink *elasticsearchSink) add(timestamp time.Time, document map[string]interface{}) {
+	sink.Lock()
+	defer sink.Unlock()
+	action, _ := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": sink.indexName(timestamp)}})
+	source, err := json.Marshal(document)
+	if err != nil {
+		sink.fail(err)
+		return
+	}
+	sink.bulk.Write(action)
+	sink.bulk.WriteByte('\n')
+	sink.bulk.Write(source)
+	sink.bulk.WriteByte('\n')
+	sink.documents++
+	if sink.documents >= elasticsearchBulkSize {
+		sink.send()
+	}
+}
+
+// send sends the bulk request. The caller must hold the lock.
+func (sink *elasticsearchSink) send() {
+	if sink.documents == 0 {
+		return
+	}
+	if err := sink.request("POST", "/_bulk", "application/x-ndjson", sink.bulk.Bytes()); err != nil {
+		sink.fail(err)
+	}
+	sink.bulk.Reset()
+	sink.documents = 0
+}
+
+// fail records the first error of the sink.
+func (sink *elasticsearchSink) fail(err error) {
+	if sink.err == nil {
+		sink.err = fmt.Errorf("elasticsearch: %v", err)
+	}
+}
+
+// flush sends the buffered documents and returns the first error since
+// the previous flush.
+func (sink *elasticsearchSink) flush() error {
+	sink.Lock()
+	defer sink.Unlock()
+	sink.send()
+	err := sink.err
+	sink.err = nil
+	return err
+}
+
+// request sends body to path on the server and checks the response,
+// including the errors of the items of a bulk request.
+func (sink *elasticsearchSink) request(method string, path string, contentType string, body []byte) error {
+	request, err := http.NewRequest(method, sink.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	response, err := sink.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s %s: %s", method, path, response.Status)
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil || !result.Errors {
+		return nil
+	}
+	for _, item := range result.Items {
+		for _, status := range item {
+			if len(status.Error) > 0 {
+				return fmt.Errorf("bulk request: %s", status.Error)
+			}
+		}
+	}
+	return fmt.Errorf("bulk request failed")
+}
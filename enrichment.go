@@ -0,0 +1,41 @@
+package main
+
+import (
+	"sync"
+)
+
+// enrichmentBudget limits the network lookups of all enrichment providers
+// during a run. A negative limit means unlimited; when offline is set no
+// lookups are allowed at all. It is safe for concurrent use.
+type enrichmentBudget struct {
+	sync.Mutex
+	remaining int
+	offline   bool
+	skipped   int
+}
+
+// newEnrichmentBudget returns a budget allowing limit lookups.
+func newEnrichmentBudget(limit int, offline bool) *enrichmentBudget {
+	return &enrichmentBudget{remaining: limit, offline: offline}
+}
+
+// take reserves one lookup and reports whether it may be done.
+func (budget *enrichmentBudget) take() bool {
+	budget.Lock()
+	defer budget.Unlock()
+	if budget.offline || budget.remaining == 0 {
+		budget.skipped++
+		return false
+	}
+	if budget.remaining > 0 {
+		budget.remaining--
+	}
+	return true
+}
+
+// skippedLookups returns the amount of lookups refused by take.
+func (budget *enrichmentBudget) skippedLookups() int {
+	budget.Lock()
+	defer budget.Unlock()
+	return budget.skipped
+}
@@ -0,0 +1,88 @@
+package main
+
+import "sort"
+
+// Name of the entry that collects the requests of evicted IP addresses.
+const otherIPAddresses = "other"
+
+// evictLowest makes room for a new IP address when there are already
+// maxIPs IP addresses, not counting the otherIPAddresses entry, by merging the IP addresses with the least requests
+// into the otherIPAddresses entry. It evicts a tenth of maxIPs at once so
+// the IP addresses are not sorted for every new address. The caller must
+// hold the lock.
+func (ipPortMapMap *ipPortMapMap) evictLowest() {
+	if ipPortMapMap.maxIPs <= 0 {
+		return
+	}
+	count := len(ipPortMapMap.ipPortMapMap)
+	if ipPortMapMap.ipPortMapMap[otherIPAddresses] != nil {
+		count--
+	}
+	if count < ipPortMapMap.maxIPs {
+		return
+	}
+
+	ipAddresses := make([]string, 0, len(ipPortMapMap.ipPortMapMap))
+	for ipAddress := range ipPortMapMap.ipPortMapMap {
+		if ipAddress != otherIPAddresses {
+			ipAddresses = append(ipAddresses, ipAddress)
+		}
+	}
+	sort.Slice(ipAddresses, func(i, j int) bool {
+		a := ipPortMapMap.ipPortMapMap[ipAddresses[i]].amountOfRequests
+		b := ipPortMapMap.ipPortMapMap[ipAddresses[j]].amountOfRequests
+		if a != b {
+			return a < b
+		}
+		return ipAddresses[i] < ipAddresses[j]
+	})
+
+	// Leave room for the new IP address, the other entry is not counted.
+	// At least one IP address is kept, except with a maxIPs of 1 where the
+	// single IP address makes room.
+	keep := ipPortMapMap.maxIPs - ipPortMapMap.maxIPs/10 - 1
+	evicted := make(map[string]bool)
+	for _, ipAddress := range ipAddresses[:len(ipAddresses)-keep] {
+		ipPortMapMap.evict(ipAddress)
+		evicted[ipAddress] = true
+	}
+
+	if ipPortMapMap.bucketsPerIP {
+		for bucket, amount := range ipPortMapMap.buckets {
+			if evicted[bucket.ipAddress] {
+				delete(ipPortMapMap.buckets, bucket)
+				bucket.ipAddress = otherIPAddresses
+				ipPortMapMap.buckets[bucket] += amount
+			}
+		}
+	}
+}
+
+// evict merges the requests of ipAddress into the otherIPAddresses entry
+// and removes ipAddress. The hosts of the evicted entries are not kept, the
+// other entry would grow without bound.
+func (ipPortMapMap *ipPortMapMap) evict(ipAddress string) {
+	other := ipPortMapMap.ipPortMapMap[otherIPAddresses]
+	if other == nil {
+		other = newIPPortMapStruct(ipPortMapMap.keys)
+		ipPortMapMap.ipPortMapMap[otherIPAddresses] = other
+	}
+
+	ipPortMapStruct := ipPortMapMap.ipPortMapMap[ipAddress]
+	other.amountOfRequests += ipPortMapStruct.amountOfRequests
+	other.retransmissions += ipPortMapStruct.retransmissions
+	other.ports.merge(ipPortMapStruct.ports)
+	other.destinations.merge(ipPortMapStruct.destinations)
+	other.tcpFlags.merge(ipPortMapStruct.tcpFlags)
+	for tag, amount := range ipPortMapStruct.tags {
+		addCount(&other.tags, tag, amount)
+	}
+	for minute, amount := range ipPortMapStruct.activity {
+		addCount(&other.activity, minute, amount)
+	}
+	if !ipPortMapStruct.firstSeen.IsZero() {
+		other.seen(ipPortMapStruct.firstSeen)
+		other.seen(ipPortMapStruct.lastSeen)
+	}
+	delete(ipPortMapMap.ipPortMapMap, ipAddress)
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestEvictLowest(t *testing.T) {
+	sources := []string{"185.220.101.4", "45.155.205.12", "185.220.101.4", "192.0.2.77", "203.0.113.50", "185.220.101.4", "45.155.205.12"}
+	for _, maxIPs := range []int{1, 2, 3, 10} {
+		ipPortMapMap := newIPPortMapMap()
+		ipPortMapMap.maxIPs = maxIPs
+		for _, source := range sources {
+			ipPortMapMap.countEntry(&logEntry{source: source, destination: "10.0.0.1", port: "22", protocol: "TCP", action: "BLOCK"})
+
+			kept := 0
+			for ipAddress := range ipPortMapMap.ipPortMapMap {
+				if ipAddress != otherIPAddresses {
+					kept++
+				}
+			}
+			if kept > maxIPs {
+				t.Errorf("maxIPs %d: kept %d IP addresses", maxIPs, kept)
+			}
+			if ipPortMapMap.ipPortMapMap[source] == nil {
+				t.Errorf("maxIPs %d: %s was not kept after its request", maxIPs, source)
+			}
+		}
+
+		// The IP address with the most requests is never evicted when
+		// there is room for more than one.
+		if top := ipPortMapMap.ipPortMapMap["185.220.101.4"]; maxIPs > 1 && (top == nil || top.amountOfRequests != 3) {
+			t.Errorf("maxIPs %d: 185.220.101.4 was evicted", maxIPs)
+		}
+
+		requests := 0
+		for _, ipPortMapStruct := range ipPortMapMap.ipPortMapMap {
+			requests += ipPortMapStruct.amountOfRequests
+		}
+		if requests != len(sources) {
+			t.Errorf("maxIPs %d: counted %d requests, want %d", maxIPs, requests, len(sources))
+		}
+	}
+}
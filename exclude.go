@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// exclusions are the destination ports and source networks that are
+// dropped before aggregation.
+type exclusions struct {
+	ports    map[string]bool
+	networks []*net.IPNet
+}
+
+// parseExclusions parses comma separated lists of ports and of IP
+// addresses or CIDRs.
+func parseExclusions(ports string, ipAddresses string) (*exclusions, error) {
+	excluded := &exclusions{ports: make(map[string]bool)}
+	for _, port := range splitList(ports) {
+		if number, err := strconv.Atoi(port); err != nil || number < 0 || number > 65535 {
+			return nil, fmt.Errorf("invalid port %q", port)
+		}
+		excluded.ports[port] = true
+	}
+	for _, address := range splitList(ipAddresses) {
+		network, err := parseNetwork(address)
+		if err != nil {
+			return nil, err
+		}
+		excluded.networks = append(excluded.networks, network)
+	}
+	return excluded, nil
+}
+
+// excludes reports whether a request from ipAddress to portNumber is
+// dropped.
+func (excluded *exclusions) excludes(ipAddress string, portNumber string) bool {
+	if excluded == nil {
+		return false
+	}
+	if excluded.ports[portNumber] {
+		return true
+	}
+	if len(excluded.networks) == 0 {
+		return false
+	}
+	ip := net.ParseIP(ipAddress)
+	for _, network := range excluded.networks {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated list, ignoring empty elements and
+// surrounding white space.
+func splitList(list string) []string {
+	var elements []string
+	for _, element := range strings.Split(list, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+)
+
+// Exit statuses of ufwLogReader. Fatal errors, like an invalid flag value,
+// exit with exitUsage through log.Fatal. Fatal I/O errors exit with
+// exitUnreadable through fatal.
+const (
+	exitSuccess    = 0
+	exitUsage      = 1
+	exitUnreadable = 2
+	exitAlerts     = 3
+)
+
+// exitStatus returns the exit status after the files were scanned: files
+// that could not be read completely take precedence over fired alerts,
+// which only count when alertExit is set.
+func exitStatus(failures []scanFailure, alerts *alertEngine, alertExit bool) int {
+	if len(failures) > 0 {
+		return exitUnreadable
+	}
+	if alertExit && alerts != nil && len(alerts.firedAlerts()) > 0 {
+		return exitAlerts
+	}
+	return exitSuccess
+}
+
+// fatal writes err like log.Fatal and exits with exitUnreadable when err is
+// an I/O error of a file, e.g. a configuration file that can't be read or
+// a dump file that can't be created, and with exitUsage otherwise.
+func fatal(err error) {
+	log.Print(err)
+	os.Exit(fatalStatus(err))
+}
+
+// fatalStatus returns the exit status fatal uses for err.
+func fatalStatus(err error) int {
+	var pathError *fs.PathError
+	if errors.As(err, &pathError) {
+		return exitUnreadable
+	}
+	return exitUsage
+}
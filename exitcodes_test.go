@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFatalStatus(t *testing.T) {
+	_, openErr := os.Open(filepath.Join(t.TempDir(), "missing.json"))
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{openErr, exitUnreadable},
+		{fmt.Errorf("config: %w", openErr), exitUnreadable},
+		{errors.New("unknown dump format \"xml\", use csv or ndjson"), exitUsage},
+	}
+	for _, test := range tests {
+		if got := fatalStatus(test.err); got != test.want {
+			t.Errorf("fatalStatus(%v) = %d, want %d", test.err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// filterExpression reports whether a request matches a -filter expression.
+type filterExpression func(entry *logEntry) bool
+
+// filterFields returns the value of every field of a request that can be
+// used in a -filter expression.
+var filterFields = map[string]func(entry *logEntry) string{
+	"src":    func(entry *logEntry) string { return entry.source },
+	"dst":    func(entry *logEntry) string { return entry.destination },
+	"dpt":    func(entry *logEntry) string { return entry.port },
+	"proto":  func(entry *logEntry) string { return entry.protocol },
+	"action": func(entry *logEntry) string { return entry.action },
+	"in":     func(entry *logEntry) string { return entry.inInterface },
+	"flags":  func(entry *logEntry) string { return entry.flags },
+}
+
+// filterParser parses a -filter expression by recursive descent:
+//
+//	expression = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expression ")" | comparison
+//	comparison = field ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) value
+//	           | field "in" value
+//	           | "tag" "==" value
+type filterParser struct {
+	tokens   []string
+	position int
+}
+
+// parseFilter parses a -filter expression like
+//
+//	dpt==22 && proto=="TCP" && src in 45.0.0.0/8
+//
+// Strings are compared case insensitively, dpt numerically. src and dst
+// are "in" a CIDR, dpt "in" a range like 6000-6010 and flags "in" a set of
+// flags when the request has all of them, e.g. flags in "SYN ACK". tag ==
+// name matches requests with that tag.
+func parseFilter(text string) (filterExpression, error) {
+	tokens, err := tokenizeFilter(text)
+	if err != nil {
+		return nil, err
+	}
+	parser := &filterParser{tokens: tokens}
+	expression, err := parser.expression()
+	if err != nil {
+		return nil, err
+	}
+	if parser.position < len(parser.tokens) {
+		return nil, fmt.Errorf("filter: unexpected %q", parser.tokens[parser.position])
+	}
+	return expression, nil
+}
+
+// tokenizeFilter splits text into operators, parentheses, quoted strings,
+// without their quotes but with a leading quote to mark them, and words.
+func tokenizeFilter(text string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			end := strings.IndexByte(text[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("filter: unterminated string at %d", i+1)
+			}
+			tokens = append(tokens, text[i:i+1+end])
+			i += end + 2
+		case strings.HasPrefix(text[i:], "&&"), strings.HasPrefix(text[i:], "||"),
+			strings.HasPrefix(text[i:], "=="), strings.HasPrefix(text[i:], "!="),
+			strings.HasPrefix(text[i:], "<="), strings.HasPrefix(text[i:], ">="):
+			tokens = append(tokens, text[i:i+2])
+			i += 2
+		case strings.IndexByte("()!<>", c) >= 0:
+			tokens = append(tokens, text[i:i+1])
+			i++
+		default:
+			start := i
+			for i < len(text) && strings.IndexByte(" \t\"()!<>=&|", text[i]) < 0 {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("filter: unexpected %q at %d", text[i], i+1)
+			}
+			tokens = append(tokens, text[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+// next returns the next token without consuming it, or "" at the end.
+func (parser *filterParser) next() string {
+	if parser.position < len(parser.tokens) {
+		return parser.tokens[parser.position]
+	}
+	return ""
+}
+
+// take consumes and returns the next token.
+func (parser *filterParser) take() string {
+	token := parser.next()
+	parser.position++
+	return token
+}
+
+// expression parses terms joined by ||.
+func (parser *filterParser) expression() (filterExpression, error) {
+	left, err := parser.and()
+	if err != nil {
+		return nil, err
+	}
+	for parser.next() == "||" {
+		parser.take()
+		right, err := parser.and()
+		if err != nil {
+			return nil, err
+		}
+		either := left
+		left = func(entry *logEntry) bool { return either(entry) || right(entry) }
+	}
+	return left, nil
+}
+
+// and parses terms joined by &&.
+func (parser *filterParser) and() (filterExpression, error) {
+	left, err := parser.unary()
+	if err != nil {
+		return nil, err
+	}
+	for parser.next() == "&&" {
+		parser.take()
+		right, err := parser.unary()
+		if err != nil {
+			return nil, err
+		}
+		both := left
+		left = func(entry *logEntry) bool { return both(entry) && right(entry) }
+	}
+	return left, nil
+}
+
+// unary parses a negation, a parenthesized expression or a comparison.
+func (parser *filterParser) unary() (filterExpression, error) {
+	switch parser.next() {
+	case "!":
+		parser.take()
+		operand, err := parser.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(entry *logEntry) bool { return !operand(entry) }, nil
+	case "(":
+		parser.take()
+		expression, err := parser.expression()
+		if err != nil {
+			return nil, err
+		}
+		if parser.take() != ")" {
+			return nil, fmt.Errorf("filter: missing )")
+		}
+		return expression, nil
+	}
+	return parser.comparison()
+}
+
+// comparison parses a field compared to a value.
+func (parser *filterParser) comparison() (filterExpression, error) {
+	field := parser.take()
+	operator := parser.take()
+	value := parser.take()
+	if value == "" {
+		return nil, fmt.Errorf("filter: incomplete comparison %q", strings.TrimSpace(field+" "+operator))
+	}
+	value = strings.TrimPrefix(value, `"`)
+
+	if field == "tag" {
+		if operator != "==" && operator != "!=" {
+			return nil, fmt.Errorf("filter: tag only supports == and !=")
+		}
+		negate := operator == "!="
+		return func(entry *logEntry) bool { return hasTag(entry.tags, []string{value}) != negate }, nil
+	}
+	get, ok := filterFields[field]
+	if !ok {
+		return nil, fmt.Errorf("filter: unknown field %q, use src, dst, dpt, proto, action, in, flags or tag", field)
+	}
+
+	if operator == "in" {
+		return filterIn(field, get, value)
+	}
+	if field == "dpt" {
+		return filterPort(get, operator, value)
+	}
+	switch operator {
+	case "==":
+		return func(entry *logEntry) bool { return strings.EqualFold(get(entry), value) }, nil
+	case "!=":
+		return func(entry *logEntry) bool { return !strings.EqualFold(get(entry), value) }, nil
+	}
+	return nil, fmt.Errorf("filter: %s doesn't support %s", field, operator)
+}
+
+// filterIn returns the expression of field in value.
+func filterIn(field string, get func(entry *logEntry) string, value string) (filterExpression, error) {
+	switch field {
+	case "src", "dst":
+		network, err := parseNetwork(value)
+		if err != nil {
+			return nil, fmt.Errorf("filter: %v", err)
+		}
+		return func(entry *logEntry) bool {
+			ip := net.ParseIP(get(entry))
+			return ip != nil && network.Contains(ip)
+		}, nil
+	case "dpt":
+		bounds := strings.SplitN(value, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		last := first
+		if err == nil && len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+		}
+		if err != nil || last < first {
+			return nil, fmt.Errorf("filter: invalid port range %q", value)
+		}
+		return func(entry *logEntry) bool {
+			port, err := strconv.Atoi(entry.port)
+			return err == nil && port >= first && port <= last
+		}, nil
+	case "flags":
+		wanted := strings.Fields(strings.ToUpper(value))
+		return func(entry *logEntry) bool {
+			flags := strings.Fields(entry.flags)
+			for _, flag := range wanted {
+				if !containsFold(flags, flag) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	}
+	return nil, fmt.Errorf("filter: %s doesn't support in", field)
+}
+
+// filterPort returns the numeric comparison of the destination port with
+// value.
+func filterPort(get func(entry *logEntry) string, operator string, value string) (filterExpression, error) {
+	wanted, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("filter: invalid port %q", value)
+	}
+	compare := map[string]func(port int) bool{
+		"==": func(port int) bool { return port == wanted },
+		"!=": func(port int) bool { return port != wanted },
+		"<":  func(port int) bool { return port < wanted },
+		"<=": func(port int) bool { return port <= wanted },
+		">":  func(port int) bool { return port > wanted },
+		">=": func(port int) bool { return port >= wanted },
+	}[operator]
+	if compare == nil {
+		return nil, fmt.Errorf("filter: unknown operator %q", operator)
+	}
+	return func(entry *logEntry) bool {
+		port, err := strconv.Atoi(get(entry))
+		return err == nil && compare(port)
+	}, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+func TestParseFilter(t *testing.T) {
+	ssh := &logEntry{source: "45.155.205.12", destination: "10.0.0.1", port: "22", protocol: "TCP", action: "BLOCK", inInterface: "eth0", flags: "SYN"}
+	rdp := &logEntry{source: "185.220.101.4", destination: "10.0.0.2", port: "3389", protocol: "TCP", action: "BLOCK", inInterface: "eth0", flags: "ACK RST", tags: []string{"remote-access"}}
+	ntp := &logEntry{source: "2001:db8::7", destination: "10.0.0.1", port: "123", protocol: "UDP", action: "AUDIT", inInterface: "eth1"}
+
+	tests := []struct {
+		filter string
+		want   [3]bool // ssh, rdp, ntp
+	}{
+		{`dpt==22`, [3]bool{true, false, false}},
+		{`proto=="tcp"`, [3]bool{true, true, false}},
+		{`action != block`, [3]bool{false, false, true}},
+		{`dpt >= 123 && dpt < 3389`, [3]bool{false, false, true}},
+		{`dpt in 1-1024`, [3]bool{true, false, true}},
+
+		// && binds stronger than ||.
+		{`dpt==22 || dpt==3389 && proto==udp`, [3]bool{true, false, false}},
+		{`(dpt==22 || dpt==3389) && proto==udp`, [3]bool{false, false, false}},
+		{`proto==udp || dpt==3389 && flags in RST`, [3]bool{false, true, true}},
+
+		// Negation applies to the operand that follows it.
+		{`!dpt==22`, [3]bool{false, true, true}},
+		{`!dpt==22 && proto==tcp`, [3]bool{false, true, false}},
+		{`!(dpt==22 && proto==tcp)`, [3]bool{false, true, true}},
+		{`!!in==eth0`, [3]bool{true, true, false}},
+
+		{`src in 45.0.0.0/8`, [3]bool{true, false, false}},
+		{`src in 2001:db8::/32`, [3]bool{false, false, true}},
+		{`dst in 10.0.0.2`, [3]bool{false, true, false}},
+		{`flags in "rst ack"`, [3]bool{false, true, false}},
+		{`tag == remote-access`, [3]bool{false, true, false}},
+		{`tag != remote-access`, [3]bool{true, false, true}},
+	}
+	for _, test := range tests {
+		expression, err := parseFilter(test.filter)
+		if err != nil {
+			t.Errorf("%s: %v", test.filter, err)
+			continue
+		}
+		for i, entry := range []*logEntry{ssh, rdp, ntp} {
+			if got := expression(entry); got != test.want[i] {
+				t.Errorf("%s: request %d: got %v, want %v", test.filter, i+1, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`dpt = 22`,
+		`dpt==22 & proto==tcp`,
+		`dpt==22 &&`,
+		`dpt==`,
+		`port==22`,
+		`proto < tcp`,
+		`dpt==ssh`,
+		`dpt in 30-20`,
+		`src in 45.0.0.0/99`,
+		`src in nowhere`,
+		`proto in tcp`,
+		`tag < x`,
+		`proto=="tcp`,
+		`(dpt==22`,
+		`((dpt==22) || proto==udp`,
+		`dpt==22)`,
+		`(dpt==22))`,
+		`()`,
+	} {
+		if _, err := parseFilter(filter); err == nil {
+			t.Errorf("%q: no error", filter)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// genUDPPorts are the destination ports generated as UDP requests, the
+// other ports are TCP SYNs.
+var genUDPPorts = map[int]bool{53: true, 69: true, 123: true, 137: true, 161: true, 1900: true, 5060: true}
+
+// genOptions configures generateLog.
+type genOptions struct {
+	lines    int
+	ips      int
+	ports    []int
+	scanners int
+	start    time.Time
+	duration time.Duration
+	seed     int64
+}
+
+// runGen runs the gen command, writing a synthetic ufw log to stdout. It
+// returns the exit status.
+func runGen(args []string) int {
+	flags := flag.NewFlagSet("gen", flag.ContinueOnError)
+	lines := flags.Int("lines", 10000, "`number` of log lines")
+	ips := flags.Int("ips", 200, "`number` of source IP addresses sending background noise")
+	ports := flags.String("ports", "22,23,80,443,445,3389,5900,8080,53,123", "comma separated destination `ports` of the noise, the first ones are the most frequent")
+	scanners := flags.Int("scanners", 3, "`number` of port scanners sweeping ports 1 to 1024")
+	start := flags.String("start", "2025-01-01T00:00:00Z", "RFC 3339 `time` of the first line")
+	duration := flags.Duration("duration", 24*time.Hour, "time range of the log")
+	seed := flags.Int64("seed", 1, "random `seed`, the same seed generates the same log")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: ufwLogReader gen [-lines n] [-ips n] [-ports list] [-scanners n] [-start time] [-duration d] [-seed n]")
+		return exitUsage
+	}
+	switch {
+	case *lines < 1:
+		fmt.Fprintln(os.Stderr, "-lines must be at least 1")
+		return exitUsage
+	case *ips < 1:
+		fmt.Fprintln(os.Stderr, "-ips must be at least 1")
+		return exitUsage
+	case *scanners < 0:
+		fmt.Fprintln(os.Stderr, "-scanners must not be negative")
+		return exitUsage
+	case *duration <= 0:
+		fmt.Fprintln(os.Stderr, "-duration must be positive")
+		return exitUsage
+	}
+
+	options := genOptions{lines: *lines, ips: *ips, scanners: *scanners, duration: *duration, seed: *seed}
+	for _, port := range splitList(*ports) {
+		number, err := strconv.Atoi(port)
+		if err != nil || number < 1 || number > 65535 {
+			fmt.Fprintf(os.Stderr, "invalid port %q\n", port)
+			return exitUsage
+		}
+		options.ports = append(options.ports, number)
+	}
+	if len(options.ports) == 0 {
+		fmt.Fprintln(os.Stderr, "-ports needs at least one port")
+		return exitUsage
+	}
+	var err error
+	options.start, err = time.Parse(time.RFC3339, *start)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-start: %v\n", err)
+		return exitUsage
+	}
+
+	writer := bufio.NewWriter(os.Stdout)
+	generateLog(writer, options)
+	if err := writer.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUnreadable
+	}
+	return exitSuccess
+}
+
+// generateLog writes the lines of a synthetic ufw log to writer: noise of
+// the source addresses to the ports, where earlier ports and addresses are
+// picked more often, and scanners sweeping ports 1 to 1024 in bursts at
+// random times. The timestamps increase evenly over the time range.
+func generateLog(writer *bufio.Writer, options genOptions) {
+	random := rand.New(rand.NewSource(options.seed))
+	sources := make([]string, options.ips)
+	for i := range sources {
+		sources[i] = genAddress(random)
+	}
+	// Zipf picks low indexes more often, like real scanning traffic.
+	sourcePicker := rand.NewZipf(random, 1.2, 1, uint64(len(sources)-1))
+	portPicker := rand.NewZipf(random, 1.5, 1, uint64(len(options.ports)-1))
+
+	type scan struct {
+		source string
+		line   int
+		port   int
+	}
+	scans := make([]scan, options.scanners)
+	for i := range scans {
+		scans[i] = scan{source: genAddress(random), line: random.Intn(options.lines), port: 1}
+	}
+
+	step := options.duration / time.Duration(options.lines)
+	for line := 0; line < options.lines; line++ {
+		timestamp := options.start.Add(time.Duration(line) * step)
+		source := sources[sourcePicker.Uint64()]
+		port := options.ports[portPicker.Uint64()]
+		for i := range scans {
+			if line >= scans[i].line && scans[i].port <= 1024 {
+				source, port = scans[i].source, scans[i].port
+				scans[i].port++
+				break
+			}
+		}
+		writeGenLine(writer, random, timestamp, source, port)
+	}
+}
+
+// writeGenLine writes a single ufw BLOCK line in the format of the kernel
+// log with the low logging level.
+func writeGenLine(writer *bufio.Writer, random *rand.Rand, timestamp time.Time, source string, port int) {
+	destination := fmt.Sprintf("10.0.0.%d", 1+random.Intn(4))
+	uptime := float64(timestamp.Unix()%1000000) + float64(timestamp.Nanosecond())/1e9
+	fmt.Fprintf(writer, "%s gateway kernel: [%12.6f] [UFW BLOCK] IN=eth0 OUT= MAC=52:54:00:12:34:56:52:54:00:65:43:21:08:00 SRC=%s DST=%s ",
+		timestamp.Format(syslogTimestampLayout), uptime, source, destination)
+	if genUDPPorts[port] {
+		fmt.Fprintf(writer, "LEN=%d TOS=0x00 PREC=0x00 TTL=%d ID=%d PROTO=UDP SPT=%d DPT=%d LEN=%d\n",
+			48+random.Intn(40), 32+random.Intn(220), random.Intn(65536), 1024+random.Intn(64512), port, 28+random.Intn(40))
+		return
+	}
+	fmt.Fprintf(writer, "LEN=40 TOS=0x00 PREC=0x00 TTL=%d ID=%d PROTO=TCP SPT=%d DPT=%d WINDOW=1024 RES=0x00 SYN URGP=0\n",
+		32+random.Intn(220), random.Intn(65536), 1024+random.Intn(64512), port)
+}
+
+// genAddress returns a random public looking IPv4 address, avoiding the
+// private, loopback and multicast ranges.
+func genAddress(random *rand.Rand) string {
+	for {
+		first := 1 + random.Intn(223)
+		if first == 10 || first == 127 || first == 172 || first == 192 {
+			continue
+		}
+		return fmt.Sprintf("%d.%d.%d.%d", first, random.Intn(256), random.Intn(256), 1+random.Intn(254))
+	}
+}
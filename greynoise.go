@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// URL of the GreyNoise community API, the IP address is appended.
+const greyNoiseURL = "https://api.greynoise.io/v3/community/"
+
+// Classifications shown in the report.
+const (
+	greyNoiseBenign    = "benign internet scanner"
+	greyNoiseMalicious = "malicious"
+	greyNoiseUnknown   = "unknown"
+)
+
+// greyNoiseResponse contains the fields of a GreyNoise community API
+// response that ufwLogReader uses.
+type greyNoiseResponse struct {
+	Noise          bool   `json:"noise"`
+	RIOT           bool   `json:"riot"`
+	Classification string `json:"classification"`
+	Name           string `json:"name"`
+}
+
+// lookupGreyNoise classifies every IP address using the GreyNoise community
+// API. IP addresses that could not be looked up, or for which the budget
+// ran out, are left out of the result.
+func lookupGreyNoise(ipAddresses []string, apiKey string, budget *enrichmentBudget, timeout time.Duration) map[string]string {
+	client := &http.Client{Timeout: timeout}
+	classifications := make(map[string]string)
+	for _, ipAddress := range ipAddresses {
+		if !budget.take() {
+			continue
+		}
+		classification, err := greyNoiseClassification(client, ipAddress, apiKey)
+		if err != nil {
+			continue
+		}
+		classifications[ipAddress] = classification
+	}
+	return classifications
+}
+
+// greyNoiseClassification looks up a single IP address. GreyNoise answers
+// 404 for addresses it has never seen, those are classified as unknown.
+func greyNoiseClassification(client *http.Client, ipAddress string, apiKey string) (string, error) {
+	request, err := http.NewRequest("GET", greyNoiseURL+ipAddress, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Accept", "application/json")
+	if apiKey != "" {
+		request.Header.Set("key", apiKey)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return greyNoiseUnknown, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("greynoise: %s: %s", ipAddress, response.Status)
+	}
+
+	var result greyNoiseResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	switch {
+	case result.Classification == "benign" || result.RIOT:
+		return greyNoiseBenign, nil
+	case result.Classification == "malicious":
+		return greyNoiseMalicious, nil
+	default:
+		return greyNoiseUnknown, nil
+	}
+}
+
+// downRankBenign moves the IP addresses GreyNoise classified as benign
+// internet scanners to the end of ipAddresses, keeping the order otherwise.
+func downRankBenign(ipAddresses []string, classifications map[string]string) []string {
+	ranked := make([]string, 0, len(ipAddresses))
+	var benign []string
+	for _, ipAddress := range ipAddresses {
+		if classifications[ipAddress] == greyNoiseBenign {
+			benign = append(benign, ipAddress)
+		} else {
+			ranked = append(ranked, ipAddress)
+		}
+	}
+	return append(ranked, benign...)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// groupStats counts the requests of a group, a tag or an alert rule: the
+// amount of requests, the distinct source addresses and the requests per
+// hour, for the trend.
+type groupStats struct {
+	requests int
+	sources  map[string]bool
+	hours    map[time.Time]int
+}
+
+// newGroupStats returns empty group statistics.
+func newGroupStats() *groupStats {
+	return &groupStats{sources: make(map[string]bool), hours: make(map[time.Time]int)}
+}
+
+// count counts a request from source. Requests without a source or
+// timestamp are only counted in the amount of requests.
+func (stats *groupStats) count(source string, timestamp time.Time, hasTimestamp bool) {
+	stats.requests++
+	if source != "" {
+		stats.sources[source] = true
+	}
+	if hasTimestamp {
+		stats.hours[timestamp.Truncate(time.Hour)]++
+	}
+}
+
+// trend compares the requests of the 24 hours up to and including the hour
+// latest with the 24 hours before, e.g. "+12%". It returns "new" when
+// there were no requests before and "-" when there are no requests in
+// either period.
+func (stats *groupStats) trend(latest time.Time) string {
+	var recent, previous int
+	for hour, amount := range stats.hours {
+		age := latest.Sub(hour)
+		switch {
+		case age < 0:
+		case age < 24*time.Hour:
+			recent += amount
+		case age < 48*time.Hour:
+			previous += amount
+		}
+	}
+	switch {
+	case previous == 0 && recent == 0:
+		return "-"
+	case previous == 0:
+		return "new"
+	}
+	return fmt.Sprintf("%+d%%", (recent-previous)*100/previous)
+}
+
+// latestHour returns the last hour with requests, or the zero time.
+func (ipPortMapMap *ipPortMapMap) latestHour() time.Time {
+	var latest time.Time
+	for hour := range ipPortMapMap.hourlyRequests {
+		if hour.After(latest) {
+			latest = hour
+		}
+	}
+	return latest
+}
+
+// printGroupStats prints the requests, distinct sources and trend of every
+// tag and alert rule, a rule-centric view next to the IP addresses and
+// ports. It prints nothing without tags or alert rules.
+func (report *report) printGroupStats(w io.Writer) {
+	latest := report.ipPortMapMap.latestHour()
+
+	tagStats := report.ipPortMapMap.tagStats
+	if len(tagStats) > 0 {
+		tags := make([]string, 0, len(tagStats))
+		for tag := range tagStats {
+			tags = append(tags, tag)
+		}
+		sort.Slice(tags, func(i, j int) bool {
+			if tagStats[tags[i]].requests != tagStats[tags[j]].requests {
+				return tagStats[tags[i]].requests > tagStats[tags[j]].requests
+			}
+			return tags[i] < tags[j]
+		})
+		fmt.Fprintln(w)
+		table := newTextTable("", []string{"Tag", "Requests", "Sources", "Trend (24h)"}, 1, 2)
+		for _, tag := range tags {
+			stats := tagStats[tag]
+			table.add(tag, report.count(stats.requests), report.count(len(stats.sources)), stats.trend(latest))
+		}
+		table.write(w)
+	}
+
+	alerts := report.ipPortMapMap.alerts
+	if alerts == nil {
+		return
+	}
+	fired := make(map[string]int)
+	for _, event := range alerts.firedAlerts() {
+		fired[event.Rule]++
+	}
+	fmt.Fprintln(w)
+	table := newTextTable("", []string{"Alert rule", "Requests", "Sources", "Alerts", "Trend (24h)"}, 1, 2, 3)
+	alerts.Lock()
+	defer alerts.Unlock()
+	for i, rule := range alerts.rules {
+		stats := alerts.stats[i]
+		table.add(rule.Name, report.count(stats.requests), report.count(len(stats.sources)), report.count(fired[rule.Name]), stats.trend(latest))
+	}
+	table.write(w)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// portTotals returns the total amount of requests for every port of a
+// port × hour of the day table.
+func portTotals(portHours map[string]*[24]int) map[string]int {
+	totals := make(map[string]int)
+	for port, hours := range portHours {
+		for _, amount := range hours {
+			totals[port] += amount
+		}
+	}
+	return totals
+}
+
+// printHeatmap prints the amount of requests per destination port and hour
+// of the day as an aligned table. Only the limit most requested ports are
+// shown, hours without requests are shown as a dot. Amounts are humanized
+// when human is set.
+func printHeatmap(w io.Writer, portHours map[string]*[24]int, limit int, human bool) {
+	ports := sortedByAmount(portTotals(portHours), limit)
+
+	cellWidth, portWidth := 2, len("Port")
+	for _, port := range ports {
+		if len(port) > portWidth {
+			portWidth = len(port)
+		}
+		for _, amount := range portHours[port] {
+			if width := len(formatCount(amount, human)); width > cellWidth {
+				cellWidth = width
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "\nRequests per port and hour of the day\n\n%-*s", portWidth, "Port")
+	for hour := 0; hour < 24; hour++ {
+		fmt.Fprintf(w, " %*s", cellWidth, fmt.Sprintf("%02d", hour))
+	}
+	fmt.Fprintln(w)
+
+	for _, port := range ports {
+		fmt.Fprintf(w, "%-*s", portWidth, port)
+		for _, amount := range portHours[port] {
+			cell := "."
+			if amount > 0 {
+				cell = formatCount(amount, human)
+			}
+			fmt.Fprintf(w, " %*s", cellWidth, cell)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// writeHeatmapCSV writes the amount of requests per destination port and
+// hour of the day as CSV, one row per port.
+func writeHeatmapCSV(w io.Writer, portHours map[string]*[24]int) error {
+	writer := csv.NewWriter(w)
+	header := []string{"port"}
+	for hour := 0; hour < 24; hour++ {
+		header = append(header, fmt.Sprintf("%02d", hour))
+	}
+	writer.Write(header)
+
+	for _, port := range sortedByAmount(portTotals(portHours), 0) {
+		record := []string{port}
+		for _, amount := range portHours[port] {
+			record = append(record, strconv.Itoa(amount))
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return writer.Error()
+}
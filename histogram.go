@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Width in characters of the longest bar in a histogram.
+const histogramWidth = 50
+
+// printHistogram prints the amount of requests per hour or per day to w,
+// with a proportional ASCII bar for every period and the coverage gaps
+// between them. Amounts are humanized when human is set.
+func printHistogram(w io.Writer, hourlyRequests map[time.Time]int, resolution string, human bool) error {
+	var layout string
+	requests := make(map[time.Time]int)
+	switch resolution {
+	case "hour":
+		layout = "2006-01-02 15:00"
+		for hour, amount := range hourlyRequests {
+			requests[hour] += amount
+		}
+	case "day":
+		layout = "2006-01-02"
+		for hour, amount := range hourlyRequests {
+			requests[startOfDay(hour)] += amount
+		}
+	default:
+		return fmt.Errorf("unknown histogram resolution %q, use hour or day", resolution)
+	}
+
+	periods := make([]time.Time, 0, len(requests))
+	highest := 0
+	for period, amount := range requests {
+		periods = append(periods, period)
+		if amount > highest {
+			highest = amount
+		}
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Before(periods[j]) })
+
+	fmt.Fprintf(w, "\nRequests per %s\n\n", resolution)
+	table := newTextTable("", []string{strings.ToUpper(resolution[:1]) + resolution[1:], "Requests"}, 1)
+	gaps := coverageGaps(hourlyRequests)
+	for _, period := range periods {
+		// Mark the gap ending before this period, so it isn't read as a
+		// quiet period.
+		for len(gaps) > 0 && gaps[0].last.Before(period) {
+			table.add("", "", fmt.Sprintf("(%s)", gaps[0]))
+			gaps = gaps[1:]
+		}
+		amount := requests[period]
+		table.add(period.Format(layout), formatCount(amount, human), strings.Repeat("#", amount*histogramWidth/highest))
+	}
+	table.write(w)
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// htmlRow is a row of a table in the HTML report. Width is the length of
+// its bar in percent of the largest amount in the table.
+type htmlRow struct {
+	Name     string
+	Label    string
+	Amount   int
+	Hosts    int
+	Ports    string
+	Notes    string
+	Severity string
+	Width    int
+}
+
+// htmlReport contains the data rendered by htmlTemplate and by the
+// -template of the user.
+type htmlReport struct {
+	Generated     string
+	TotalRequests int
+	Sources       int
+	MostRequested string
+	UnknownSource int
+	IPAddresses   []htmlRow
+	Ports         []htmlRow
+	Destinations  []htmlRow
+	Hours         []htmlRow
+}
+
+// writeHTML writes the report as a single self-contained HTML file, with
+// sortable and filterable tables, source IP addresses colored by severity
+// and inline bar charts. It uses no external resources.
+func (report *report) writeHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, report.htmlData())
+}
+
+// htmlData returns the tables of the report, with the bar widths set.
+func (report *report) htmlData() htmlReport {
+	totalRequests, portRequests, destinationRequests := report.totals()
+	data := htmlReport{
+		Generated:     time.Now().Format(time.RFC1123),
+		TotalRequests: totalRequests,
+		Sources:       len(report.reportedIPAddresses()),
+		MostRequested: report.services.withService(getMostRequestedPort(portRequests)),
+		UnknownSource: report.ipPortMapMap.unknownSource.amountOfRequests,
+	}
+
+	for _, ipAddress := range report.reportedIPAddresses() {
+		ipPortMapStruct := report.ipPortMapMap.ipPortMapMap[ipAddress]
+		var ports []string
+		for _, portNumber := range sortedByAmount(ipPortMapStruct.portCounts(), 5) {
+			ports = append(ports, report.services.withService(portNumber))
+		}
+		data.IPAddresses = append(data.IPAddresses, htmlRow{
+			Name:     ipAddress,
+			Label:    report.labels.lookup(ipAddress),
+			Amount:   ipPortMapStruct.amountOfRequests,
+			Hosts:    uniqueHosts(ipPortMapStruct),
+			Ports:    strings.Join(ports, ", "),
+			Notes:    report.notes(ipAddress),
+			Severity: report.severity(ipAddress),
+		})
+	}
+	for _, portNumber := range sortedByAmount(portRequests, 0) {
+		data.Ports = append(data.Ports, htmlRow{Name: portNumber, Label: report.services[portNumber], Amount: portRequests[portNumber]})
+	}
+	for _, destination := range sortedByAmount(destinationRequests, 0) {
+		data.Destinations = append(data.Destinations, htmlRow{
+			Name:   destination,
+			Label:  report.labels.lookup(destination),
+			Amount: destinationRequests[destination],
+		})
+	}
+
+	hours := make([]time.Time, 0, len(report.ipPortMapMap.hourlyRequests))
+	for hour := range report.ipPortMapMap.hourlyRequests {
+		hours = append(hours, hour)
+	}
+	sort.Slice(hours, func(i, j int) bool { return hours[i].Before(hours[j]) })
+	for _, hour := range hours {
+		data.Hours = append(data.Hours, htmlRow{Name: hour.Format("2006-01-02 15:00"), Amount: report.ipPortMapMap.hourlyRequests[hour]})
+	}
+
+	for _, rows := range [][]htmlRow{data.IPAddresses, data.Ports, data.Destinations, data.Hours} {
+		setBarWidths(rows)
+	}
+	return data
+}
+
+// setBarWidths sets the bar width of every row relative to the largest
+// amount.
+func setBarWidths(rows []htmlRow) {
+	highest := 0
+	for _, row := range rows {
+		if row.Amount > highest {
+			highest = row.Amount
+		}
+	}
+	for i := range rows {
+		if highest > 0 {
+			rows[i].Width = rows[i].Amount * 100 / highest
+		}
+	}
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>ufw report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 0.3em 0.8em; border-bottom: 1px solid #ddd; text-align: left; }
+th { cursor: pointer; background: #f4f4f4; user-select: none; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.bar { background: #c0392b; height: 0.8em; }
+.bars { width: 200px; }
+.summary td:first-child { font-weight: bold; }
+.hours { display: flex; align-items: flex-end; height: 120px; gap: 1px; margin-bottom: 2em; }
+.hours div { background: #2980b9; flex: 1; min-width: 2px; }
+tr.high { background: #f8d7da; }
+tr.medium { background: #fff3cd; }
+tr.low { background: #ffffff; }
+td.severity { font-weight: bold; text-transform: uppercase; font-size: 0.8em; }
+.filters { margin-bottom: 0.5em; }
+.filters input, .filters select { padding: 0.2em; margin-right: 0.5em; }
+</style>
+</head>
+<body>
+<h1>ufw report</h1>
+<p>Generated {{.Generated}}</p>
+
+<table class="summary">
+<tr><td>Total amount of requests</td><td class="num">{{.TotalRequests}}</td></tr>
+<tr><td>Source IP addresses</td><td class="num">{{.Sources}}</td></tr>
+<tr><td>Most requested port</td><td>{{.MostRequested}}</td></tr>
+{{if .UnknownSource}}<tr><td>Requests without a source IP address</td><td class="num">{{.UnknownSource}}</td></tr>{{end}}
+</table>
+
+{{if .Hours}}<h2>Requests per hour</h2>
+<div class="hours">{{range .Hours}}<div style="height: {{.Width}}%" title="{{.Name}}: {{.Amount}}"></div>{{end}}</div>
+{{end}}
+<h2>Source IP addresses</h2>
+<div class="filters">
+<input type="search" placeholder="Filter" data-filter="sources">
+<select data-severity="sources"><option value="">All severities</option><option value="high">High</option><option value="medium">Medium or high</option></select>
+</div>
+<table class="sortable" id="sources">
+<thead><tr><th data-type="severity">Severity</th><th>IP address</th><th>Label</th><th data-type="num">Requests</th><th data-type="num">Hosts</th><th>Top ports</th><th>Notes</th><th></th></tr></thead>
+<tbody>{{range .IPAddresses}}
+<tr class="{{.Severity}}"><td class="severity">{{.Severity}}</td><td>{{.Name}}</td><td>{{.Label}}</td><td class="num">{{.Amount}}</td><td class="num">{{.Hosts}}</td><td>{{.Ports}}</td><td>{{.Notes}}</td><td class="bars"><div class="bar" style="width: {{.Width}}%"></div></td></tr>{{end}}
+</tbody>
+</table>
+
+<h2>Ports</h2>
+<div class="filters"><input type="search" placeholder="Filter" data-filter="ports"></div>
+<table class="sortable" id="ports">
+<thead><tr><th data-type="num">Port</th><th>Service</th><th data-type="num">Requests</th><th></th></tr></thead>
+<tbody>{{range .Ports}}
+<tr><td>{{.Name}}</td><td>{{.Label}}</td><td class="num">{{.Amount}}</td><td class="bars"><div class="bar" style="width: {{.Width}}%"></div></td></tr>{{end}}
+</tbody>
+</table>
+
+<h2>Destination addresses</h2>
+<div class="filters"><input type="search" placeholder="Filter" data-filter="destinations"></div>
+<table class="sortable" id="destinations">
+<thead><tr><th>Destination IP</th><th>Label</th><th data-type="num">Requests</th><th></th></tr></thead>
+<tbody>{{range .Destinations}}
+<tr><td>{{.Name}}</td><td>{{.Label}}</td><td class="num">{{.Amount}}</td><td class="bars"><div class="bar" style="width: {{.Width}}%"></div></td></tr>{{end}}
+</tbody>
+</table>
+
+<script>
+var severityRank = { low: 0, medium: 1, high: 2 };
+
+function applyFilters(table) {
+	var input = document.querySelector("[data-filter=" + table.id + "]");
+	var select = document.querySelector("[data-severity=" + table.id + "]");
+	var text = input ? input.value.toLowerCase() : "";
+	var minimum = select && select.value ? severityRank[select.value] : 0;
+	Array.prototype.forEach.call(table.tBodies[0].rows, function (row) {
+		var visible = row.textContent.toLowerCase().indexOf(text) >= 0;
+		if (row.className in severityRank) {
+			visible = visible && severityRank[row.className] >= minimum;
+		}
+		row.style.display = visible ? "" : "none";
+	});
+}
+
+document.querySelectorAll("[data-filter], [data-severity]").forEach(function (control) {
+	var table = document.getElementById(control.dataset.filter || control.dataset.severity);
+	control.addEventListener("input", function () { applyFilters(table); });
+});
+
+document.querySelectorAll("table.sortable th").forEach(function (th) {
+	th.addEventListener("click", function () {
+		var table = th.closest("table");
+		var body = table.tBodies[0];
+		var column = Array.prototype.indexOf.call(th.parentNode.children, th);
+		var type = th.dataset.type;
+		var ascending = !th.classList.contains("asc");
+		table.querySelectorAll("th").forEach(function (other) { other.classList.remove("asc", "desc"); });
+		th.classList.add(ascending ? "asc" : "desc");
+		var rows = Array.prototype.slice.call(body.rows);
+		rows.sort(function (a, b) {
+			var x = a.cells[column].textContent, y = b.cells[column].textContent;
+			var order;
+			if (type === "num") {
+				order = Number(x) - Number(y);
+			} else if (type === "severity") {
+				order = severityRank[x] - severityRank[y];
+			} else {
+				order = x.localeCompare(y);
+			}
+			return ascending ? order : -order;
+		});
+		rows.forEach(function (row) { body.appendChild(row); });
+	});
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// formatCount formats amount exactly, or when human is set rounded with a
+// k, M or G suffix, e.g. 1.2M.
+func formatCount(amount int, human bool) string {
+	if !human {
+		return strconv.Itoa(amount)
+	}
+	value := float64(amount)
+	for _, suffix := range []string{"", "k", "M", "G"} {
+		if value < 1000 || suffix == "G" {
+			if suffix == "" {
+				return strconv.Itoa(amount)
+			}
+			if value < 10 {
+				return fmt.Sprintf("%.1f%s", value, suffix)
+			}
+			return fmt.Sprintf("%.0f%s", value, suffix)
+		}
+		value /= 1000
+	}
+	return strconv.Itoa(amount)
+}
+
+// formatBytes formats size in bytes with a kB, MB, GB or TB suffix, e.g.
+// 1.2 GB.
+func formatBytes(size int64) string {
+	if size < 1000 {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / 1000
+	for _, suffix := range []string{"kB", "MB", "GB"} {
+		if value < 1000 {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= 1000
+	}
+	return fmt.Sprintf("%.1f TB", value)
+}
+
+// formatDuration formats duration rounded to whole seconds, e.g. 1m20s.
+func formatDuration(duration time.Duration) string {
+	return duration.Round(time.Second).String()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// influxSink writes every event as an InfluxDB line protocol point, e.g.
+// "ufw_block,src=203.0.113.7,dst=10.0.0.1,proto=tcp,dpt=22,in=eth0
+// count=1i 1798334514000000000", ready for Telegraf or the InfluxDB write
+// API. When redaction is set the events are redacted before they are
+// written.
+type influxSink struct {
+	sync.Mutex
+	writer    *bufio.Writer
+	redaction *redaction
+	err       error
+}
+
+// newInfluxSink returns a sink writing points to w.
+func newInfluxSink(w io.Writer) *influxSink {
+	return &influxSink{writer: bufio.NewWriter(w)}
+}
+
+// influxEscaper escapes measurement names and tag values.
+var influxEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// influxMeasurement returns the measurement of the requests of a ufw
+// action, e.g. ufw_block or ufw_limit_block.
+func influxMeasurement(action string) string {
+	if action == "" {
+		return "ufw_request"
+	}
+	return "ufw_" + strings.ReplaceAll(strings.ToLower(action), " ", "_")
+}
+
+// writeEvent writes the point of event. Points of events without a
+// timestamp get the time at which they are written to InfluxDB.
+func (sink *influxSink) writeEvent(event *sinkEvent) {
+	event = sink.redaction.apply(event)
+	var point strings.Builder
+	point.WriteString(influxEscaper.Replace(influxMeasurement(event.action)))
+	tags := []string{
+		"src", event.source,
+		"dst", event.destination,
+		"proto", strings.ToLower(event.protocol),
+		"dpt", event.port,
+		"in", event.inInterface,
+	}
+	for i := 0; i < len(tags); i += 2 {
+		if tags[i+1] != "" {
+			point.WriteString("," + tags[i] + "=" + influxEscaper.Replace(tags[i+1]))
+		}
+	}
+	point.WriteString(" count=1i")
+	if !event.timestamp.IsZero() {
+		point.WriteString(" " + strconv.FormatInt(event.timestamp.UnixNano(), 10))
+	}
+	point.WriteByte('\n')
+
+	sink.Lock()
+	defer sink.Unlock()
+	if _, err := sink.writer.WriteString(point.String()); err != nil && sink.err == nil {
+		sink.err = fmt.Errorf("influx: %v", err)
+	}
+}
+
+// flush writes the buffered points and returns the first error since the
+// previous flush.
+func (sink *influxSink) flush() error {
+	sink.Lock()
+	defer sink.Unlock()
+	if err := sink.writer.Flush(); err != nil && sink.err == nil {
+		sink.err = fmt.Errorf("influx: %v", err)
+	}
+	err := sink.err
+	sink.err = nil
+	return err
+}
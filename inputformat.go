@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// inputFormatParser returns a parser that only accepts the lines of parse
+// written by the firewall of format: auto accepts every line with a
+// destination port, ufw the lines of ufw and firewalld the lines of
+// firewalld's LOG rules, like "FINAL_REJECT: " and
+// "filter_IN_public_REJECT: ". pf and windows lines are not kernel log
+// lines, they are read by parsePF and parseWindowsFirewall instead of
+// parse.
+func inputFormatParser(parse lineParser, format string) (lineParser, error) {
+	switch format {
+	case "auto":
+		return parse, nil
+	case "ufw":
+		return func(line []byte, entry *logEntry) bool {
+			return bytes.Contains(line, tokenUFW) && parse(line, entry)
+		}, nil
+	case "firewalld":
+		return func(line []byte, entry *logEntry) bool {
+			action, ok := firewalldAction(line)
+			if !ok || !parse(line, entry) {
+				return false
+			}
+			entry.action = action
+			return true
+		}, nil
+	case "pf":
+		return parsePF, nil
+	case "windows":
+		return parseWindowsFirewall, nil
+	}
+	return nil, fmt.Errorf("unknown input format %q, use auto, ufw, firewalld, pf or windows", format)
+}
+
+// firewalldAction returns the action of the firewalld log prefix of line,
+// the part after the last underscore, e.g. REJECT for FINAL_REJECT and
+// filter_IN_public_REJECT. It reports whether line has such a prefix.
+func firewalldAction(line []byte) (string, bool) {
+	in := bytes.Index(line, []byte("IN="))
+	if in < 0 {
+		return "", false
+	}
+	prefix := bytes.TrimRight(line[:in], " ")
+	if !bytes.HasSuffix(prefix, []byte(":")) {
+		return "", false
+	}
+	prefix = prefix[:len(prefix)-1]
+	if space := bytes.LastIndexByte(prefix, ' '); space >= 0 {
+		prefix = prefix[space+1:]
+	}
+	underscore := bytes.LastIndexByte(prefix, '_')
+	if underscore < 0 {
+		return "", false
+	}
+	switch action := string(prefix[underscore+1:]); action {
+	case "REJECT", "DROP", "ACCEPT":
+		return action, true
+	}
+	return "", false
+}
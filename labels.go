@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// networkLabel couples a network from the labels file to its label, for
+// example "Partner VPN range / Networking / vpn-gw-01".
+type networkLabel struct {
+	network *net.IPNet
+	label   string
+}
+
+// labelTable holds all networks from a labels file. Lookups return the label
+// of the most specific network containing the IP address.
+type labelTable struct {
+	networks []networkLabel
+}
+
+// loadLabelTable reads a CSV file where the first column is an IP address
+// or CIDR and the remaining columns (owner, team, asset, ...) form the label.
+// Lines starting with # are ignored.
+func loadLabelTable(filename string) (*labelTable, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	labels := new(labelTable)
+	for lineNumber, record := range records {
+		if len(record) < 2 {
+			return nil, fmt.Errorf("%s: record %d: expected an address and a label", filename, lineNumber+1)
+		}
+		network, err := parseNetwork(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: record %d: %v", filename, lineNumber+1, err)
+		}
+
+		var parts []string
+		for _, part := range record[1:] {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		labels.networks = append(labels.networks, networkLabel{network, strings.Join(parts, " / ")})
+	}
+	return labels, nil
+}
+
+// parseNetwork parses a CIDR or a single IP address, the latter is treated
+// as a network containing only that address.
+func parseNetwork(address string) (*net.IPNet, error) {
+	if strings.Contains(address, "/") {
+		_, network, err := net.ParseCIDR(address)
+		return network, err
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %q", address)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// lookup returns the label of the most specific network containing
+// ipAddress, or an empty string if no network matches. For a subnet in
+// CIDR notation its network address is looked up.
+func (labels *labelTable) lookup(ipAddress string) string {
+	if labels == nil {
+		return ""
+	}
+	ip := net.ParseIP(ipAddress)
+	if subnetIP, _, err := net.ParseCIDR(ipAddress); err == nil {
+		ip = subnetIP
+	}
+	if ip == nil {
+		return ""
+	}
+
+	label := ""
+	longestPrefix := -1
+	for _, entry := range labels.networks {
+		prefix, _ := entry.network.Mask.Size()
+		if prefix > longestPrefix && entry.network.Contains(ip) {
+			label = entry.label
+			longestPrefix = prefix
+		}
+	}
+	return label
+}
+
+// withLabel appends the label of ipAddress between parentheses, if any.
+func (labels *labelTable) withLabel(ipAddress string) string {
+	if label := labels.lookup(ipAddress); label != "" {
+		return fmt.Sprintf("%s (%s)", ipAddress, label)
+	}
+	return ipAddress
+}
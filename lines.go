@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// The default maximum length of a log line, the token limit of
+// bufio.Scanner that was used before.
+const defaultMaxLineBytes = 64 * 1024
+
+// lineReader reads lines of at most maxLineBytes bytes. Longer lines are
+// skipped and counted in oversized, the lines after them are still read.
+// Only maxLineBytes of a long line are kept in memory.
+type lineReader struct {
+	reader       *bufio.Reader
+	maxLineBytes int
+	line         []byte
+	oversized    int
+}
+
+// newLineReader returns a lineReader reading from r.
+func newLineReader(r io.Reader, maxLineBytes int) *lineReader {
+	return &lineReader{reader: bufio.NewReader(r), maxLineBytes: maxLineBytes}
+}
+
+// readLine returns the next line without its line ending. The line is only
+// valid until the next call. At the end of the input it returns io.EOF.
+func (lineReader *lineReader) readLine() ([]byte, error) {
+	for {
+		lineReader.line = lineReader.line[:0]
+		tooLong := false
+		var err error
+		for {
+			var chunk []byte
+			chunk, err = lineReader.reader.ReadSlice('\n')
+			// Leave room for the line ending.
+			if len(lineReader.line)+len(chunk) > lineReader.maxLineBytes+2 {
+				tooLong = true
+			}
+			if !tooLong {
+				lineReader.line = append(lineReader.line, chunk...)
+			}
+			if err != bufio.ErrBufferFull {
+				break
+			}
+		}
+
+		line := bytes.TrimRight(lineReader.line, "\r\n")
+		if tooLong || len(line) > lineReader.maxLineBytes {
+			lineReader.oversized++
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil && (err != io.EOF || len(lineReader.line) == 0) {
+			return nil, err
+		}
+		return line, nil
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Escape sequences moving the cursor home and clearing the screen.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// liveCounts are the requests counted in a single second.
+type liveCounts struct {
+	sources map[string]int
+	ports   map[string]int
+}
+
+// liveView is the top-style view of follow -live. It counts the requests
+// per second, as an event sink, and draws the request rates per IP address
+// and port over the last window, rather than the totals since the start.
+type liveView struct {
+	sync.Mutex
+	window  time.Duration
+	rows    int
+	started time.Time
+	seconds map[int64]*liveCounts
+}
+
+// newLiveView returns a view of the rates over window showing rows IP
+// addresses and ports.
+func newLiveView(window time.Duration, rows int) *liveView {
+	return &liveView{window: window, rows: rows, started: time.Now(), seconds: make(map[int64]*liveCounts)}
+}
+
+// writeEvent counts event in the current second.
+func (view *liveView) writeEvent(event *sinkEvent) {
+	second := time.Now().Unix()
+	view.Lock()
+	defer view.Unlock()
+	counts := view.seconds[second]
+	if counts == nil {
+		counts = &liveCounts{sources: make(map[string]int), ports: make(map[string]int)}
+		view.seconds[second] = counts
+	}
+	source := event.source
+	if source == "" {
+		source = unknownSourceLabel
+	}
+	counts.sources[source]++
+	counts.ports[event.port]++
+}
+
+// flush does nothing, the view is drawn by draw every second.
+func (view *liveView) flush() error {
+	return nil
+}
+
+// draw clears the screen and draws the requests of the last window at now
+// with their rate per minute. The rates of the first window are computed
+// over the time since the start.
+func (view *liveView) draw(w io.Writer, now time.Time, services serviceTable) {
+	oldest := now.Add(-view.window).Unix()
+	total := 0
+	sources := make(map[string]int)
+	ports := make(map[string]int)
+	view.Lock()
+	for second, counts := range view.seconds {
+		if second <= oldest {
+			delete(view.seconds, second)
+			continue
+		}
+		for source, amount := range counts.sources {
+			sources[source] += amount
+			total += amount
+		}
+		for port, amount := range counts.ports {
+			ports[port] += amount
+		}
+	}
+	view.Unlock()
+
+	span := view.window
+	if elapsed := now.Sub(view.started); elapsed < span {
+		span = elapsed
+	}
+	minutes := span.Minutes()
+	if minutes <= 0 {
+		minutes = 1.0 / 60
+	}
+
+	fmt.Fprint(w, clearScreen)
+	fmt.Fprintf(w, "ufw live at %s, last %s: %d requests, %.1f/min\n\n", now.Format("15:04:05"), view.window, total, float64(total)/minutes)
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(table, "Source IP\tRequests\tPer minute\t\n")
+	for _, source := range sortedByAmount(sources, view.rows) {
+		fmt.Fprintf(table, "%s\t%d\t%.1f\t\n", source, sources[source], float64(sources[source])/minutes)
+	}
+	fmt.Fprintf(table, "\t\t\t\n")
+	fmt.Fprintf(table, "Port\tRequests\tPer minute\t\n")
+	for _, port := range sortedByAmount(ports, view.rows) {
+		fmt.Fprintf(table, "%s\t%d\t%.1f\t\n", services.withService(port), ports[port], float64(ports[port])/minutes)
+	}
+	table.Flush()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// logFile is a log file opened for reading. Files ending in .gz, like the
+// ones logrotate compresses, are decompressed while they are read.
+// modified is the modification time of the file, zero when it isn't a
+// regular file on disk.
+type logFile struct {
+	io.Reader
+	compressed   bool
+	modified     time.Time
+	decompressor *gzip.Reader
+	file         *os.File
+}
+
+// openLog opens the log file filename.
+func openLog(filename string) (*logFile, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	logFile, err := newLogFile(filename, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	logFile.file = file
+	if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+		logFile.modified = info.ModTime()
+	}
+	return logFile, nil
+}
+
+// newLogFile returns the log file called filename read from reader, so
+// files that are not opened by openLog, like the embedded selftest logs,
+// are decompressed the same way. Closing it doesn't close reader.
+func newLogFile(filename string, reader io.Reader) (*logFile, error) {
+	if !strings.HasSuffix(filename, ".gz") {
+		return &logFile{Reader: reader}, nil
+	}
+	decompressor, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &logFile{Reader: decompressor, compressed: true, decompressor: decompressor}, nil
+}
+
+// Close closes the decompressor and the file opened by openLog.
+func (file *logFile) Close() error {
+	if file.decompressor != nil {
+		file.decompressor.Close()
+	}
+	if file.file != nil {
+		return file.file.Close()
+	}
+	return nil
+}
+
+// yearReference returns the time the year of yearless timestamps is
+// inferred from: the modification time of the file, or now.
+func (file *logFile) yearReference() time.Time {
+	if file.modified.IsZero() {
+		return time.Now()
+	}
+	return file.modified
+}
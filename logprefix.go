@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// logPrefixParser returns a parser that only accepts the lines of parse
+// that carry one of the --log-prefix values of iptables LOG rules, e.g.
+// "DROPPED: ", in front of their fields. Lines without an action get the
+// prefix as their action, without its punctuation, e.g. DROPPED.
+func logPrefixParser(parse lineParser, prefixes []string) lineParser {
+	type logPrefix struct {
+		prefix []byte
+		action string
+	}
+	var logPrefixes []logPrefix
+	for _, prefix := range prefixes {
+		action := strings.Trim(prefix, " :[]-_")
+		logPrefixes = append(logPrefixes, logPrefix{[]byte(strings.TrimRight(prefix, " ")), action})
+	}
+
+	return func(line []byte, entry *logEntry) bool {
+		fields := line
+		if in := bytes.Index(line, []byte("IN=")); in >= 0 {
+			fields = line[:in]
+		}
+		for _, logPrefix := range logPrefixes {
+			if bytes.Contains(fields, logPrefix.prefix) {
+				if !parse(line, entry) {
+					return false
+				}
+				if entry.action == "" {
+					entry.action = logPrefix.action
+				}
+				return true
+			}
+		}
+		return false
+	}
+}
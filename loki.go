@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The amount of log lines sent in a single Loki push request.
+const lokiBatchSize = 1000
+
+// lokiSink pushes events to Grafana Loki as logfmt lines, in streams
+// labeled with the job, action, protocol and input interface of the
+// events. When redaction is set the events are redacted before they are
+// pushed.
+type lokiSink struct {
+	sync.Mutex
+	url       string
+	job       string
+	redaction *redaction
+	client    *http.Client
+	streams   map[lokiLabels][]lokiLine
+	lines     int
+	err       error
+}
+
+// lokiLabels are the labels of a Loki stream. They are kept to a few
+// values with a low cardinality, the addresses and ports are in the lines.
+type lokiLabels struct {
+	action      string
+	protocol    string
+	inInterface string
+}
+
+// lokiLine is a log line of a Loki stream.
+type lokiLine struct {
+	timestamp int64
+	line      string
+}
+
+// newLokiSink returns a sink pushing to the Loki server at url, with job
+// as the value of the job label.
+func newLokiSink(url string, job string) *lokiSink {
+	return &lokiSink{
+		url:     strings.TrimRight(url, "/") + "/loki/api/v1/push",
+		job:     job,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		streams: make(map[lokiLabels][]lokiLine),
+	}
+}
+
+// writeEvent adds event to the streams and pushes them when the batch is
+// full. Events without a timestamp get the current time.
+func (sink *lokiSink) writeEvent(event *sinkEvent) {
+	event = sink.redaction.apply(event)
+	timestamp := event.timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	labels := lokiLabels{
+		action:      event.action,
+		protocol:    strings.ToLower(event.protocol),
+		inInterface: event.inInterface,
+	}
+	line := logfmt("src", event.source, "dst", event.destination, "dpt", event.port, "flags", event.flags)
+
+	sink.Lock()
+	defer sink.Unlock()
+	sink.streams[labels] = append(sink.streams[labels], lokiLine{timestamp.UnixNano(), line})
+	sink.lines++
+	if sink.lines >= lokiBatchSize {
+		sink.push()
+	}
+}
+
+// logfmt formats the key value pairs as a logfmt line, leaving out empty
+// values.
+func logfmt(pairs ...string) string {
+	var line strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			continue
+		}
+		if line.Len() > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(pairs[i])
+		line.WriteByte('=')
+		if strings.ContainsAny(pairs[i+1], " \"=") {
+			line.WriteString(strconv.Quote(pairs[i+1]))
+		} else {
+			line.WriteString(pairs[i+1])
+		}
+	}
+	return line.String()
+}
+
+// push sends the streams to Loki. The lines of every stream are sorted by
+// time, lines of multiple files are interleaved otherwise. The caller must
+// hold the lock.
+func (sink *lokiSink) push() {
+	if sink.lines == 0 {
+		return
+	}
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	var request struct {
+		Streams []stream `json:"streams"`
+	}
+	for labels, lines := range sink.streams {
+		sort.SliceStable(lines, func(i, j int) bool { return lines[i].timestamp < lines[j].timestamp })
+		values := make([][2]string, len(lines))
+		for i, line := range lines {
+			values[i] = [2]string{strconv.FormatInt(line.timestamp, 10), line.line}
+		}
+		streamLabels := map[string]string{"job": sink.job}
+		for name, value := range map[string]string{"action": labels.action, "proto": labels.protocol, "interface": labels.inInterface} {
+			if value != "" {
+				streamLabels[name] = value
+			}
+		}
+		request.Streams = append(request.Streams, stream{Stream: streamLabels, Values: values})
+	}
+	sink.streams = make(map[lokiLabels][]lokiLine)
+	sink.lines = 0
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		sink.fail(err)
+		return
+	}
+	response, err := sink.client.Post(sink.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		sink.fail(err)
+		return
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		sink.fail(fmt.Errorf("push: %s", response.Status))
+	}
+}
+
+// fail records the first error of the sink.
+func (sink *lokiSink) fail(err error) {
+	if sink.err == nil {
+		sink.err = fmt.Errorf("loki: %v", err)
+	}
+}
+
+// flush pushes the buffered lines and returns the first error since the
+// previous flush.
+func (sink *lokiSink) flush() error {
+	sink.Lock()
+	defer sink.Unlock()
+	sink.push()
+	err := sink.err
+	sink.err = nil
+	return err
+}
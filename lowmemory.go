@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"runtime/debug"
+)
+
+// lowMemoryDefaults are the flag values of the -low-memory profile. Flags
+// given on the command line take precedence.
+var lowMemoryDefaults = map[string]string{
+	"concurrency":    "1",
+	"max-ips":        "10000",
+	"max-line-bytes": "4096",
+	"parsers":        "1",
+	"read-queue":     "2",
+	"parse-queue":    "2",
+}
+
+// The garbage collection target percentage of the -low-memory profile, the
+// heap may grow 20% instead of 100% before the next collection.
+const lowMemoryGCPercent = 20
+
+// applyLowMemoryProfile sets the flags of the -low-memory profile that were
+// not given on the command line and makes the garbage collector run more
+// often.
+func applyLowMemoryProfile(flags *flag.FlagSet) error {
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, value := range lowMemoryDefaults {
+		if given[name] {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return err
+		}
+	}
+	debug.SetGCPercent(lowMemoryGCPercent)
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The maximum size of a statsd datagram, so it fits in a single packet.
+const statsdPacketSize = 1432
+
+// metricsSink counts the requests, the requests per action and port and
+// the unique source addresses, and emits them to a statsd or Graphite
+// endpoint every interval in daemon mode. The counters start from zero
+// after every emit. When redaction is set the events are redacted before
+// they are counted, so dropped ports have no counter and masked sources
+// are counted per network.
+type metricsSink struct {
+	sync.Mutex
+	protocol  string
+	address   string
+	prefix    string
+	interval  time.Duration
+	redaction *redaction
+
+	requests int
+	actions  map[string]int
+	ports    map[string]int
+	sources  map[string]bool
+}
+
+// newMetricsSink returns a sink emitting to endpoint, statsd://host:port
+// or graphite://host:port, with metric names starting with prefix.
+func newMetricsSink(endpoint string, prefix string, interval time.Duration) (*metricsSink, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "statsd" && parsed.Scheme != "graphite" {
+		return nil, fmt.Errorf("unknown metrics endpoint %q, use statsd://host:port or graphite://host:port", endpoint)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid metrics interval %s", interval)
+	}
+	sink := &metricsSink{protocol: parsed.Scheme, address: parsed.Host, prefix: prefix, interval: interval}
+	sink.reset()
+	return sink, nil
+}
+
+// reset starts the counters from zero.
+func (sink *metricsSink) reset() {
+	sink.requests = 0
+	sink.actions = make(map[string]int)
+	sink.ports = make(map[string]int)
+	sink.sources = make(map[string]bool)
+}
+
+// writeEvent counts event.
+func (sink *metricsSink) writeEvent(event *sinkEvent) {
+	event = sink.redaction.apply(event)
+	sink.Lock()
+	defer sink.Unlock()
+	sink.requests++
+	if event.action != "" {
+		sink.actions[strings.ReplaceAll(strings.ToLower(event.action), " ", "_")]++
+	}
+	if event.port != "" {
+		sink.ports[event.port]++
+	}
+	if event.source != "" {
+		sink.sources[event.source] = true
+	}
+}
+
+// flush does nothing, the counters are sent by emit every interval.
+func (sink *metricsSink) flush() error {
+	return nil
+}
+
+// emit sends the counters to the endpoint and starts them from zero.
+func (sink *metricsSink) emit(now time.Time) error {
+	sink.Lock()
+	metrics := map[string]int{
+		"requests": sink.requests,
+		"sources":  len(sink.sources),
+	}
+	for action, count := range sink.actions {
+		metrics["action."+action] = count
+	}
+	for port, count := range sink.ports {
+		metrics["port."+port] = count
+	}
+	sink.reset()
+	sink.Unlock()
+
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		metric := name
+		if sink.prefix != "" {
+			metric = sink.prefix + "." + name
+		}
+		switch {
+		case sink.protocol == "graphite":
+			lines = append(lines, fmt.Sprintf("%s %d %d\n", metric, metrics[name], now.Unix()))
+		case name == "sources":
+			lines = append(lines, fmt.Sprintf("%s:%d|g\n", metric, metrics[name]))
+		default:
+			lines = append(lines, fmt.Sprintf("%s:%d|c\n", metric, metrics[name]))
+		}
+	}
+
+	network := "udp"
+	if sink.protocol == "graphite" {
+		network = "tcp"
+	}
+	connection, err := dialTimeout(network, sink.address, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("metrics: %v", err)
+	}
+	defer connection.Close()
+
+	// Graphite reads a stream, statsd datagrams are kept below the
+	// packet size.
+	var payload bytes.Buffer
+	for _, line := range lines {
+		if network == "udp" && payload.Len() > 0 && payload.Len()+len(line) > statsdPacketSize {
+			if _, err := connection.Write(payload.Bytes()); err != nil {
+				return fmt.Errorf("metrics: %v", err)
+			}
+			payload.Reset()
+		}
+		payload.WriteString(line)
+	}
+	if _, err := connection.Write(payload.Bytes()); err != nil {
+		return fmt.Errorf("metrics: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapSupported reports whether -mmap memory maps the files. Windows has
+// no syscall.Mmap, so the files are read.
+const mmapSupported = false
+
+// mapFile returns an error, files are not memory mapped on Windows.
+func mapFile(file *os.File) ([]byte, error) {
+	return nil, errors.New("memory mapping is not supported on Windows")
+}
+
+// unmapFile releases data returned by mapFile.
+func unmapFile(data []byte) error {
+	return nil
+}
+
+// peakRSS returns zero, the peak resident set size of a process isn't
+// available on Windows.
+func peakRSS(state *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// errNetworkDisabled is returned for every connection attempt after
+// disableNetwork.
+var errNetworkDisabled = errors.New("network access is disabled by -no-network")
+
+// dialTimeout connects to address, like net.DialTimeout. The probes and
+// the metrics sink connect through it, so disableNetwork can refuse their
+// connections as well.
+var dialTimeout = net.DialTimeout
+
+// noNetworkTransport refuses every HTTP request.
+type noNetworkTransport struct{}
+
+// RoundTrip refuses request.
+func (noNetworkTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	return nil, errNetworkDisabled
+}
+
+// disableNetwork makes every HTTP request, DNS lookup and dialTimeout
+// connection of the process fail, so a feature that is missed by the -no-network checks still can't
+// reach the network.
+func disableNetwork() {
+	http.DefaultTransport = noNetworkTransport{}
+	dialTimeout = func(network string, address string, timeout time.Duration) (net.Conn, error) {
+		return nil, errNetworkDisabled
+	}
+	net.DefaultResolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			return nil, errNetworkDisabled
+		},
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDisableNetworkRefusesProbes(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	defaultTransport, defaultResolver, defaultDial := http.DefaultTransport, net.DefaultResolver, dialTimeout
+	defer func() {
+		http.DefaultTransport, net.DefaultResolver, dialTimeout = defaultTransport, defaultResolver, defaultDial
+	}()
+	disableNetwork()
+
+	if _, err := dialTimeout("tcp", listener.Addr().String(), time.Second); !errors.Is(err, errNetworkDisabled) {
+		t.Fatalf("dialTimeout after disableNetwork = %v, want %v", err, errNetworkDisabled)
+	}
+	results := probeHosts([]string{"127.0.0.1"}, []string{port}, newEnrichmentBudget(1, false), time.Second, 0)
+	if got := results["127.0.0.1"]; got != probeNoResponse {
+		t.Errorf("probe after disableNetwork = %q, want %q", got, probeNoResponse)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// logEntry contains the fields of a single log line that ufwLogReader
+// uses. Fields missing from the line are empty. yearless is set for
+// timestamps without a year. tags are attached by the tag rules before the
+// entry is counted.
+type logEntry struct {
+	timestamp    time.Time
+	hasTimestamp bool
+	yearless     bool
+	action       string
+	inInterface  string
+	source       string
+	destination  string
+	protocol     string
+	port         string
+	flags        string
+	tags         []string
+}
+
+// lineParser parses a log line into entry. It reports whether the line
+// contained a destination port.
+type lineParser func(line []byte, entry *logEntry) bool
+
+// newLineParser returns the parser with the given name: fields, the
+// single-pass key=value tokenizer, or regexp, the original regular
+// expression based parser.
+func newLineParser(name string) (lineParser, bool) {
+	switch name {
+	case "fields":
+		return parseFields, true
+	case "regexp":
+		return newLogPatterns().parse, true
+	}
+	return nil, false
+}
+
+// tcpFlags are the TCP flag tokens that the kernel logs between RES= and
+// URGP=.
+var tcpFlags = map[string]bool{
+	"CWR": true, "ECE": true, "URG": true, "ACK": true,
+	"PSH": true, "RST": true, "SYN": true, "FIN": true,
+}
+
+// Keys of the fields read by parseFields.
+var (
+	keySource      = []byte("SRC")
+	keyDestination = []byte("DST")
+	keyProtocol    = []byte("PROTO")
+	keyPort        = []byte("DPT")
+	keyReserved    = []byte("RES")
+	keyIn          = []byte("IN")
+	tokenUFW       = []byte("[UFW")
+)
+
+// parseFields parses a log line in a single pass over its space separated
+// tokens without regular expressions. Bare tokens following RES= are
+// collected as TCP flags until the next key=value token, the tokens
+// following [UFW up to the closing bracket are the action. The keys are
+// looked up by name, so the field order of the nftables backend and
+// prefixes glued to the first key are handled as well.
+func parseFields(line []byte, entry *logEntry) bool {
+	parseTimestamp(line, entry)
+
+	inFlags := false
+	inAction := false
+	var flags, action []byte
+	for len(line) > 0 {
+		var token []byte
+		if space := bytes.IndexByte(line, ' '); space >= 0 {
+			token, line = line[:space], line[space+1:]
+		} else {
+			token, line = line, nil
+		}
+		if len(token) == 0 {
+			continue
+		}
+		if inAction {
+			if len(action) > 0 {
+				action = append(action, ' ')
+			}
+			bracket := bytes.IndexByte(token, ']')
+			if bracket < 0 {
+				action = append(action, token...)
+				continue
+			}
+			// nftables prefixes may lack the trailing space, e.g.
+			// "[UFW BLOCK]IN=eth0".
+			action = append(action, token[:bracket]...)
+			token, inAction = token[bracket+1:], false
+			if len(token) == 0 {
+				continue
+			}
+		}
+		if bytes.Equal(token, tokenUFW) {
+			inAction = true
+			continue
+		}
+
+		equals := bytes.IndexByte(token, '=')
+		if equals < 0 {
+			if inFlags && tcpFlags[string(token)] {
+				if len(flags) > 0 {
+					flags = append(flags, ' ')
+				}
+				flags = append(flags, token...)
+			}
+			continue
+		}
+
+		inFlags = false
+		key, value := token[:equals], token[equals+1:]
+		switch {
+		case bytes.Equal(key, keySource):
+			entry.source = string(value)
+		case bytes.Equal(key, keyDestination):
+			entry.destination = string(value)
+		case bytes.Equal(key, keyProtocol):
+			entry.protocol = string(value)
+		case bytes.Equal(key, keyPort):
+			if isPortNumber(value) {
+				entry.port = string(value)
+			}
+		case bytes.Equal(key, keyReserved):
+			inFlags = true
+		case bytes.Equal(key, keyIn) || isPrefixedIn(key):
+			entry.inInterface = string(value)
+		}
+	}
+	entry.action = string(action)
+	entry.flags = string(flags)
+	return entry.port != ""
+}
+
+// isPrefixedIn reports whether key is the IN key glued to a log prefix
+// without a trailing space, e.g. "DROP:IN" of an nftables log rule.
+func isPrefixedIn(key []byte) bool {
+	if len(key) < 3 || !bytes.HasSuffix(key, keyIn) {
+		return false
+	}
+	previous := key[len(key)-3]
+	return !(previous >= 'A' && previous <= 'Z' || previous >= 'a' && previous <= 'z' || previous >= '0' && previous <= '9')
+}
+
+// isPortNumber reports whether value consists of one to five digits.
+func isPortNumber(value []byte) bool {
+	if len(value) == 0 || len(value) > 5 {
+		return false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// logPatterns holds the regular expressions that extract the fields of a ufw
+// log line.
+type logPatterns struct {
+	action *regexp.Regexp
+	in     *regexp.Regexp
+	ip     *regexp.Regexp
+	port   *regexp.Regexp
+	dst    *regexp.Regexp
+	proto  *regexp.Regexp
+	flags  *regexp.Regexp
+}
+
+// newLogPatterns compiles the regular expressions used by the regexp
+// parser.
+func newLogPatterns() *logPatterns {
+	return &logPatterns{
+		action: regexp.MustCompile(`\[UFW ([^\]]+)\]`),
+		in:     regexp.MustCompile(`\bIN=(\S*)`),
+		ip:     regexp.MustCompile(`SRC=(\d{1,3}.\d{1,3}.\d{1,3}.\d{1,3})`),
+		port:   regexp.MustCompile(`DPT=(\d{1,5})`),
+		dst:    regexp.MustCompile(`DST=(\d{1,3}.\d{1,3}.\d{1,3}.\d{1,3})`),
+		proto:  regexp.MustCompile(`PROTO=(\w+)`),
+		flags:  regexp.MustCompile(`RES=\S+((?: [A-Z]+)*) URGP=`),
+	}
+}
+
+// parse parses a log line using the regular expressions.
+func (patterns *logPatterns) parse(line []byte, entry *logEntry) bool {
+	text := string(line)
+	parseTimestamp(line, entry)
+	if match := patterns.action.FindStringSubmatch(text); match != nil {
+		entry.action = match[1]
+	}
+	if match := patterns.in.FindStringSubmatch(text); match != nil {
+		entry.inInterface = match[1]
+	}
+	if match := patterns.ip.FindStringSubmatch(text); match != nil {
+		entry.source = match[1]
+	}
+	if match := patterns.dst.FindStringSubmatch(text); match != nil {
+		entry.destination = match[1]
+	}
+	if match := patterns.proto.FindStringSubmatch(text); match != nil {
+		entry.protocol = match[1]
+	}
+	if match := patterns.flags.FindStringSubmatch(text); match != nil {
+		entry.flags = tcpFlagSet(match[1])
+	}
+	if match := patterns.port.FindStringSubmatch(text); match != nil {
+		entry.port = match[1]
+	}
+	return entry.port != ""
+}
+
+// tcpFlagSet returns the TCP flags in tokens joined by spaces, e.g. "ACK
+// FIN", ignoring tokens that are not TCP flags.
+func tcpFlagSet(tokens string) string {
+	var flagSet []string
+	for _, token := range strings.Fields(tokens) {
+		if tcpFlags[token] {
+			flagSet = append(flagSet, token)
+		}
+	}
+	return strings.Join(flagSet, " ")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// benchmarkFixture is the log the parser benchmarks parse.
+const benchmarkFixture = "selftest/fixtures/ufw.log"
+
+// fixtureLines returns the lines of the fixture log called filename.
+func fixtureLines(tb testing.TB, filename string) [][]byte {
+	tb.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return bytes.Split(bytes.TrimRight(data, "\n"), []byte{'\n'})
+}
+
+// benchmarkParser measures parse on the lines of benchmarkFixture, the
+// bytes per second are the bytes of the lines.
+func benchmarkParser(b *testing.B, parse lineParser) {
+	lines := fixtureLines(b, benchmarkFixture)
+	size := 0
+	for _, line := range lines {
+		size += len(line)
+	}
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			var entry logEntry
+			parse(line, &entry)
+		}
+	}
+}
+
+func BenchmarkParseFields(b *testing.B) {
+	benchmarkParser(b, parseFields)
+}
+
+func BenchmarkParseRegexp(b *testing.B) {
+	benchmarkParser(b, newLogPatterns().parse)
+}
+
+// TestParsersAgree checks that the fields parser returns the same fields
+// as the regexp parser for every line of the selftest fixtures.
+func TestParsersAgree(t *testing.T) {
+	patterns := newLogPatterns()
+	for _, fixture := range []string{"ufw.log", "iso8601.log", "nftables.log", "firewalld.log"} {
+		t.Run(fixture, func(t *testing.T) {
+			for i, line := range fixtureLines(t, "selftest/fixtures/"+fixture) {
+				var fields, regexp logEntry
+				fieldsRequest := parseFields(line, &fields)
+				regexpRequest := patterns.parse(line, &regexp)
+				if fieldsRequest != regexpRequest || fields.action != regexp.action || fields.inInterface != regexp.inInterface ||
+					fields.source != regexp.source || fields.destination != regexp.destination || fields.protocol != regexp.protocol ||
+					fields.port != regexp.port || fields.flags != regexp.flags || !fields.timestamp.Equal(regexp.timestamp) {
+					t.Errorf("line %d: fields parser returned %+v, regexp parser %+v", i+1, fields, regexp)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// pfTCPFlags are the TCP flag characters of tcpdump and the flag names of
+// the kernel log, in the order of the kernel log.
+var pfTCPFlags = []struct {
+	char byte
+	name string
+}{
+	{'W', "CWR"}, {'E', "ECE"}, {'U', "URG"}, {'.', "ACK"},
+	{'P', "PSH"}, {'R', "RST"}, {'S', "SYN"}, {'F', "FIN"},
+}
+
+// parsePF parses a line of "tcpdump -n -e -ttt -r /var/log/pflog" on
+// OpenBSD, e.g.
+//
+//	Feb 03 14:04:13.622843 rule 0/(match) block in on em0: 192.0.2.55.41288 > 10.0.0.1.22: S 1534271040:1534271040(0) win 16384
+//
+// The action is the pf action, block or pass, the ports are the last
+// dot separated parts of the addresses. TCP packets are recognized by
+// their flags, other packets with ports are UDP. Packets without ports,
+// like ICMP, have no destination port.
+func parsePF(line []byte, entry *logEntry) bool {
+	parseTimestamp(line, entry)
+
+	match := bytes.Index(line, []byte("(match) "))
+	if match < 0 {
+		return false
+	}
+	fields := strings.Fields(string(line[match+len("(match) "):]))
+	// block in on em0: src > dst: ...
+	if len(fields) < 7 || fields[2] != "on" || fields[5] != ">" {
+		return false
+	}
+	entry.action = strings.ToUpper(fields[0])
+	entry.inInterface = strings.TrimSuffix(fields[3], ":")
+	if fields[1] != "in" {
+		entry.inInterface = ""
+	}
+
+	source, _ := splitPFAddress(fields[4])
+	destination, port := splitPFAddress(strings.TrimSuffix(fields[6], ":"))
+	entry.source = source
+	entry.destination = destination
+
+	rest := fields[7:]
+	switch {
+	case len(rest) == 0 || strings.HasPrefix(rest[0], "icmp"):
+		return false
+	case isPFFlags(rest[0]):
+		entry.protocol = "TCP"
+		var flags []string
+		for _, flag := range pfTCPFlags {
+			if strings.IndexByte(rest[0], flag.char) >= 0 {
+				flags = append(flags, flag.name)
+			}
+		}
+		entry.flags = strings.Join(flags, " ")
+	default:
+		entry.protocol = "UDP"
+	}
+
+	if !isPortNumber([]byte(port)) {
+		return false
+	}
+	entry.port = port
+	return true
+}
+
+// splitPFAddress splits an address of tcpdump, like 192.0.2.55.41288 or
+// 2001:db8::1.443, into the IP address and the port.
+func splitPFAddress(address string) (string, string) {
+	dot := strings.LastIndexByte(address, '.')
+	if dot < 0 {
+		return address, ""
+	}
+	return address[:dot], address[dot+1:]
+}
+
+// isPFFlags reports whether token consists of tcpdump TCP flag characters,
+// e.g. "S" or "F.".
+func isPFFlags(token string) bool {
+	for i := 0; i < len(token); i++ {
+		found := false
+		for _, flag := range pfTCPFlags {
+			found = found || flag.char == token[i]
+		}
+		if !found {
+			return false
+		}
+	}
+	return token != ""
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The maximum amount of lines passed between the pipeline stages at once.
+const pipelineChunkLines = 1024
+
+// scanOptions configures scanFiles. At most concurrency files are read at
+// the same time and parsers goroutines parse their lines. readQueue and
+// parseQueue are the amount of chunks of lines that can wait for the parse
+// and aggregate stages. When progress is set the progress of every file is
+// reported. Lines longer than maxLineBytes are skipped. With mmap the files
+// are memory mapped instead of read, except for gzip compressed files. When
+// rejects is set the lines that are not requests are written to it.
+type scanOptions struct {
+	parse        lineParser
+	concurrency  int
+	parsers      int
+	readQueue    int
+	parseQueue   int
+	progress     *progress
+	maxLineBytes int
+	mmap         bool
+	rejects      *rejectsWriter
+}
+
+// lineChunk is a chunk of lines of a file, passed from the read stage to
+// the parse stage. The chunks of a file are numbered from zero by seq, so
+// the aggregate stage can count them in order. numbers are the line
+// numbers of the lines in the file, counting the skipped long lines as
+// well. When done is set it is called once the lines are parsed.
+type lineChunk struct {
+	file    int
+	seq     int
+	lines   [][]byte
+	numbers []int
+	done    func()
+}
+
+// entryChunk is a lineChunk after parsing, only containing the requests
+// and, when they are written to a rejects file, the other lines.
+type entryChunk struct {
+	file     int
+	seq      int
+	entries  []logEntry
+	rejected []rejectedLine
+}
+
+// scanFiles scans the files in a pipeline of three stages connected by
+// channels: reading the files, at most options.concurrency at the same
+// time, parsing their lines and counting the requests in ipPortMapMap.
+// Slow disks and fast CPUs, or the other way around, are both kept busy.
+// The requests of a file are counted in the order of its lines. Files that
+// can't be opened or read are skipped, they are returned in the order of
+// filenames.
+func scanFiles(filenames []string, ipPortMapMap *ipPortMapMap, options scanOptions) []scanFailure {
+	concurrency := atLeastOne(options.concurrency)
+	chunks := make(chan lineChunk, options.readQueue)
+	entryChunks := make(chan entryChunk, options.parseQueue)
+	failures := make([]*scanFailure, len(filenames))
+	stats := make([]scanStats, len(filenames))
+	durations := make([]time.Duration, len(filenames))
+	requests := make([]int64, len(filenames))
+	malformed := make([]int64, len(filenames))
+
+	jobs := make(chan int)
+	var readers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for job := range jobs {
+				started := time.Now()
+				var err error
+				stats[job], err = readFile(job, filenames[job], options, chunks)
+				durations[job] = time.Since(started)
+				if err != nil {
+					failures[job] = &scanFailure{filename: filenames[job], lines: stats[job].lines, err: err}
+				}
+				if stats[job].oversized > 0 {
+					infof("%s: skipped %d lines longer than %d bytes, see -max-line-bytes\n", filenames[job], stats[job].oversized, options.maxLineBytes)
+				}
+			}
+		}()
+	}
+
+	var parsers sync.WaitGroup
+	for i := 0; i < atLeastOne(options.parsers); i++ {
+		parsers.Add(1)
+		go func() {
+			defer parsers.Done()
+			for chunk := range chunks {
+				entries := make([]logEntry, 0, len(chunk.lines))
+				var rejected []rejectedLine
+				for i, line := range chunk.lines {
+					var entry logEntry
+					request, broken := parseSafely(options.parse, line, &entry)
+					if broken {
+						atomic.AddInt64(&malformed[chunk.file], 1)
+					}
+					if request {
+						entries = append(entries, entry)
+					} else if options.rejects != nil {
+						// Copied, mapped files are unmapped once parsed.
+						text := append([]byte(nil), line...)
+						rejected = append(rejected, rejectedLine{number: chunk.numbers[i], text: text})
+					}
+				}
+				if chunk.done != nil {
+					chunk.done()
+				}
+				atomic.AddInt64(&requests[chunk.file], int64(len(entries)))
+				entryChunks <- entryChunk{file: chunk.file, seq: chunk.seq, entries: entries, rejected: rejected}
+			}
+		}()
+	}
+
+	aggregated := make(chan struct{})
+	go func() {
+		aggregateChunks(entryChunks, filenames, ipPortMapMap, options.rejects)
+		close(aggregated)
+	}()
+
+	for job := range filenames {
+		jobs <- job
+	}
+	close(jobs)
+	readers.Wait()
+	close(chunks)
+	parsers.Wait()
+	close(entryChunks)
+	<-aggregated
+
+	for job, amount := range malformed {
+		if amount > 0 {
+			infof("%s: skipped %d malformed lines the parser couldn't handle, see -rejects\n", filenames[job], amount)
+		}
+	}
+
+	var failed []scanFailure
+	for job, failure := range failures {
+		if failure != nil {
+			failed = append(failed, *failure)
+			continue
+		}
+		verbosef("%s: %d lines, %d requests, %d other lines skipped, read in %s\n", filenames[job], stats[job].lines, requests[job],
+			int64(stats[job].lines)-requests[job], durations[job].Round(time.Millisecond))
+	}
+	return failed
+}
+
+// readFile is the read stage of scanFiles for a single file. Files ending
+// in .gz are decompressed. It sends the lines of filename in chunks and
+// returns the amount of lines read and skipped, the requests are counted
+// later by the parse stage.
+func readFile(job int, filename string, options scanOptions, chunks chan<- lineChunk) (scanStats, error) {
+	var stats scanStats
+	file, err := os.Open(filename)
+	if err != nil {
+		return stats, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	var fileProgress *fileProgress
+	if options.progress != nil {
+		var size int64
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+			size = info.Size()
+		}
+		fileProgress = options.progress.start(filename, size, file)
+		reader = fileProgress
+		defer options.progress.finish(fileProgress)
+	}
+
+	logFile, err := newLogFile(filename, reader)
+	if err != nil {
+		return stats, err
+	}
+	defer logFile.Close()
+	if options.mmap && mmapSupported && !logFile.compressed {
+		return readMapped(job, file, options.maxLineBytes, fileProgress, chunks)
+	}
+	if fileProgress != nil {
+		logFile.Reader = fileProgress.countLines(logFile.Reader)
+	}
+
+	lineReader := newLineReader(logFile, options.maxLineBytes)
+	seq := 0
+	var data []byte
+	var ends, numbers []int
+	send := func() {
+		lines := make([][]byte, len(ends))
+		start := 0
+		for i, end := range ends {
+			lines[i] = data[start:end]
+			start = end
+		}
+		chunks <- lineChunk{file: job, seq: seq, lines: lines, numbers: numbers}
+		seq++
+		// The next chunk most likely needs as much room.
+		data, ends, numbers = make([]byte, 0, cap(data)), make([]int, 0, pipelineChunkLines), make([]int, 0, pipelineChunkLines)
+	}
+	for {
+		line, err := lineReader.readLine()
+		stats.oversized = lineReader.oversized
+		if err != nil {
+			if len(ends) > 0 {
+				send()
+			}
+			if err == io.EOF {
+				return stats, nil
+			}
+			return stats, err
+		}
+		stats.lines++
+		data = append(data, line...)
+		ends = append(ends, len(data))
+		numbers = append(numbers, stats.lines+stats.oversized)
+		if len(ends) == pipelineChunkLines {
+			send()
+		}
+	}
+}
+
+// readMapped is the read stage of scanFiles for a memory mapped file. Its
+// chunks refer to the mapped memory, so it is only unmapped after all
+// chunks are parsed.
+func readMapped(job int, file *os.File, maxLineBytes int, fileProgress *fileProgress, chunks chan<- lineChunk) (scanStats, error) {
+	var stats scanStats
+	data, err := mapFile(file)
+	if err != nil {
+		return stats, err
+	}
+
+	var parsed sync.WaitGroup
+	seq := 0
+	var lines [][]byte
+	var numbers []int
+	send := func() {
+		parsed.Add(1)
+		chunks <- lineChunk{file: job, seq: seq, lines: lines, numbers: numbers, done: parsed.Done}
+		seq++
+		lines, numbers = nil, nil
+	}
+	remaining := data
+	for len(remaining) > 0 {
+		line := remaining
+		next := len(remaining)
+		if end := bytes.IndexByte(remaining, '\n'); end >= 0 {
+			line = remaining[:end]
+			next = end + 1
+		}
+		remaining = remaining[next:]
+		if fileProgress != nil {
+			fileProgress.advance(next)
+		}
+
+		if len(line) > 0 && line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+		if len(line) > maxLineBytes {
+			stats.oversized++
+			continue
+		}
+		stats.lines++
+		lines = append(lines, line)
+		numbers = append(numbers, stats.lines+stats.oversized)
+		if len(lines) == pipelineChunkLines {
+			send()
+		}
+	}
+	if len(lines) > 0 {
+		send()
+	}
+
+	parsed.Wait()
+	return stats, unmapFile(data)
+}
+
+// aggregateChunks is the aggregate stage of scanFiles. It counts the
+// requests of the chunks of every file in order, holding back chunks that
+// were parsed before the chunks preceding them. The years of yearless
+// timestamps are inferred from the modification time of the file. When
+// rejects is set the rejected lines are written to it in order as well.
+func aggregateChunks(entryChunks <-chan entryChunk, filenames []string, ipPortMapMap *ipPortMapMap, rejects *rejectsWriter) {
+	next := make([]int, len(filenames))
+	pending := make([]map[int]entryChunk, len(filenames))
+	years := make([]*yearInference, len(filenames))
+	for chunk := range entryChunks {
+		if pending[chunk.file] == nil {
+			pending[chunk.file] = make(map[int]entryChunk)
+			years[chunk.file] = newYearInference(modificationTime(filenames[chunk.file]))
+		}
+		pending[chunk.file][chunk.seq] = chunk
+		for {
+			ready, ok := pending[chunk.file][next[chunk.file]]
+			if !ok {
+				break
+			}
+			delete(pending[chunk.file], next[chunk.file])
+			next[chunk.file]++
+			for i := range ready.entries {
+				years[chunk.file].infer(&ready.entries[i])
+				ipPortMapMap.countEntry(&ready.entries[i])
+			}
+			if rejects != nil {
+				rejects.write(filenames[chunk.file], ready.rejected)
+			}
+		}
+	}
+}
+
+// checkQueueSize returns an error when size, the value of the queue flag
+// called name, is negative. A channel can't have a negative buffer.
+func checkQueueSize(name string, size int) error {
+	if size < 0 {
+		return fmt.Errorf("-%s must not be negative, got %d", name, size)
+	}
+	return nil
+}
+
+// atLeastOne returns n, or one when n is smaller.
+func atLeastOne(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
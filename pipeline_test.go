@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestCheckQueueSize(t *testing.T) {
+	tests := []struct {
+		size  int
+		valid bool
+	}{
+		{-1, false},
+		{-16, false},
+		{0, true},
+		{16, true},
+	}
+	for _, test := range tests {
+		err := checkQueueSize("read-queue", test.size)
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("checkQueueSize(%d) = %v, want valid %t", test.size, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// portRange is a named range of destination ports, bounds included.
+type portRange struct {
+	name  string
+	first int
+	last  int
+}
+
+// ianaPortRanges are the port ranges defined by IANA.
+var ianaPortRanges = []portRange{
+	{"well-known", 0, 1023},
+	{"registered", 1024, 49151},
+	{"ephemeral", 49152, 65535},
+}
+
+// parsePortRanges parses "iana" or a comma separated list of name=first-last
+// or name=port elements, e.g. "privileged=0-1023,high=1024-65535".
+func parsePortRanges(list string) ([]portRange, error) {
+	if list == "iana" {
+		return ianaPortRanges, nil
+	}
+
+	var ranges []portRange
+	for _, element := range splitList(list) {
+		nameBounds := strings.SplitN(element, "=", 2)
+		if len(nameBounds) != 2 || nameBounds[0] == "" {
+			return nil, fmt.Errorf("invalid port range %q, use name=first-last", element)
+		}
+		bounds := strings.SplitN(nameBounds[1], "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid port range %q: %v", element, err)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("invalid port range %q: %v", element, err)
+			}
+		}
+		if first < 0 || last > 65535 || first > last {
+			return nil, fmt.Errorf("invalid port range %q", element)
+		}
+		ranges = append(ranges, portRange{nameBounds[0], first, last})
+	}
+	return ranges, nil
+}
+
+// portRangeOf returns the name and bounds of the first range containing
+// portNumber, e.g. "well-known (0-1023)". Ports outside every range are
+// returned unchanged.
+func portRangeOf(portNumber string, ranges []portRange) string {
+	port, err := strconv.Atoi(portNumber)
+	if err != nil {
+		return portNumber
+	}
+	for _, r := range ranges {
+		if port >= r.first && port <= r.last {
+			if r.first == r.last {
+				return fmt.Sprintf("%s (%d)", r.name, r.first)
+			}
+			return fmt.Sprintf("%s (%d-%d)", r.name, r.first, r.last)
+		}
+	}
+	return portNumber
+}
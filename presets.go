@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// alertPresets are the alert rules that can be enabled by name with the
+// preset field of a rule. The other fields of the rule override those of
+// the preset.
+var alertPresets = map[string]alertRule{
+	"ssh-bruteforce": {Name: "ssh-bruteforce", Port: "22", Protocol: "TCP", Threshold: 100, Window: "10m"},
+	"rdp-scan":       {Name: "rdp-scan", Port: "3389", Protocol: "TCP", Threshold: 20, Window: "10m"},
+	"smb-worm":       {Name: "smb-worm", Port: "445", Protocol: "TCP", Threshold: 50, Window: "5m"},
+	"ntp-reflection": {Name: "ntp-reflection", Port: "123", Protocol: "UDP", Threshold: 100, Window: "1m"},
+}
+
+// presetNames returns the names of the alert presets in alphabetical
+// order.
+func presetNames() []string {
+	names := make([]string, 0, len(alertPresets))
+	for name := range alertPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyPreset replaces rule by its preset, overridden by the fields that
+// are set in rule.
+func (rule *alertRule) applyPreset() error {
+	preset, ok := alertPresets[rule.Preset]
+	if !ok {
+		return fmt.Errorf("unknown preset %q, use one of %v", rule.Preset, presetNames())
+	}
+	for _, override := range []struct{ value, preset *string }{
+		{&rule.Name, &preset.Name},
+		{&rule.Port, &preset.Port},
+		{&rule.Protocol, &preset.Protocol},
+		{&rule.Source, &preset.Source},
+		{&rule.Window, &preset.Window},
+		{&rule.Action, &preset.Action},
+		{&rule.URL, &preset.URL},
+		{&rule.Command, &preset.Command},
+	} {
+		if *override.value != "" {
+			*override.preset = *override.value
+		}
+	}
+	if rule.Threshold > 0 {
+		preset.Threshold = rule.Threshold
+	}
+	preset.Preset = rule.Preset
+	*rule = preset
+	return nil
+}
+
+// printPresets prints a table of the alert presets.
+func printPresets(w io.Writer) {
+	writer := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(writer, "Preset\tPort\tProtocol\tThreshold\tWindow\n")
+	for _, name := range presetNames() {
+		preset := alertPresets[name]
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%s\n", name, preset.Port, preset.Protocol, preset.Threshold, preset.Window)
+	}
+	writer.Flush()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// probeNoResponse is the probe result of a host that did not answer on any
+// of the probed ports.
+const probeNoResponse = "no response"
+
+// probeHosts checks whether the IP addresses are still alive with a single
+// TCP connect per port, in order, until a port answers. A refused
+// connection also means the host is alive. Connects are made one at a
+// time, at most one every interval, and every IP address takes one lookup
+// of the budget. Subnets and anonymized addresses are not probed.
+func probeHosts(ipAddresses []string, ports []string, budget *enrichmentBudget, timeout time.Duration, interval time.Duration) map[string]string {
+	results := make(map[string]string)
+	var last time.Time
+	for _, ipAddress := range ipAddresses {
+		if net.ParseIP(ipAddress) == nil || !budget.take() {
+			continue
+		}
+		results[ipAddress] = probeNoResponse
+		for _, port := range ports {
+			if wait := interval - time.Since(last); wait > 0 {
+				time.Sleep(wait)
+			}
+			last = time.Now()
+			connection, err := dialTimeout("tcp", net.JoinHostPort(ipAddress, port), timeout)
+			if err == nil {
+				connection.Close()
+				results[ipAddress] = "alive, port " + port + " open"
+				break
+			}
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				results[ipAddress] = "alive, port " + port + " refused"
+				break
+			}
+		}
+	}
+	return results
+}
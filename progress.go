@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progress periodically writes the progress of the files that are being
+// read to output.
+type progress struct {
+	sync.Mutex
+	output io.Writer
+	files  []*fileProgress
+	done   chan struct{}
+}
+
+// fileProgress counts the bytes read from a file of size bytes and the
+// lines read from it. The size is zero when it is unknown, e.g. for pipes.
+type fileProgress struct {
+	name      string
+	size      int64
+	started   time.Time
+	reader    io.Reader
+	bytesRead int64
+	lines     int64
+}
+
+// newProgress starts writing the progress of the files being read to output
+// every interval, until stop is called.
+func newProgress(output io.Writer, interval time.Duration) *progress {
+	progress := &progress{output: output, done: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				progress.print()
+			case <-progress.done:
+				return
+			}
+		}
+	}()
+	return progress
+}
+
+// stop stops writing progress.
+func (progress *progress) stop() {
+	close(progress.done)
+}
+
+// start tracks the progress of reading file and returns the reader the file
+// must be read through.
+func (progress *progress) start(name string, size int64, file io.Reader) *fileProgress {
+	fileProgress := &fileProgress{name: name, size: size, started: time.Now(), reader: file}
+	progress.Lock()
+	progress.files = append(progress.files, fileProgress)
+	progress.Unlock()
+	return fileProgress
+}
+
+// finish stops tracking fileProgress and writes its final statistics.
+func (progress *progress) finish(fileProgress *fileProgress) {
+	progress.Lock()
+	defer progress.Unlock()
+	for i, other := range progress.files {
+		if other == fileProgress {
+			progress.files = append(progress.files[:i], progress.files[i+1:]...)
+			break
+		}
+	}
+	elapsed := time.Since(fileProgress.started)
+	fmt.Fprintf(progress.output, "%s: %s, %d lines in %s\n", fileProgress.name,
+		formatBytes(atomic.LoadInt64(&fileProgress.bytesRead)), atomic.LoadInt64(&fileProgress.lines), formatDuration(elapsed))
+}
+
+// print writes a progress line for every file that is being read.
+func (progress *progress) print() {
+	progress.Lock()
+	defer progress.Unlock()
+	now := time.Now()
+	for _, fileProgress := range progress.files {
+		fmt.Fprintln(progress.output, fileProgress.status(now))
+	}
+}
+
+// status describes the progress at now: bytes read out of the size, lines
+// per second and the estimated time until the file is read.
+func (fileProgress *fileProgress) status(now time.Time) string {
+	bytesRead := atomic.LoadInt64(&fileProgress.bytesRead)
+	lines := atomic.LoadInt64(&fileProgress.lines)
+	elapsed := now.Sub(fileProgress.started).Seconds()
+	linesPerSecond := 0
+	if elapsed > 0 {
+		linesPerSecond = int(float64(lines) / elapsed)
+	}
+
+	if fileProgress.size <= 0 {
+		return fmt.Sprintf("%s: %s, %s lines/s", fileProgress.name, formatBytes(bytesRead), formatCount(linesPerSecond, true))
+	}
+	status := fmt.Sprintf("%s: %s / %s (%d%%), %s lines/s", fileProgress.name, formatBytes(bytesRead),
+		formatBytes(fileProgress.size), bytesRead*100/fileProgress.size, formatCount(linesPerSecond, true))
+	if bytesRead > 0 && bytesRead < fileProgress.size {
+		remaining := time.Duration(float64(fileProgress.size-bytesRead) / float64(bytesRead) * elapsed * float64(time.Second))
+		status += ", ETA " + formatDuration(remaining)
+	}
+	return status
+}
+
+// Read reads from the file and counts the bytes read. The lines are
+// counted by the reader returned by countLines, after decompression.
+func (fileProgress *fileProgress) Read(p []byte) (int, error) {
+	n, err := fileProgress.reader.Read(p)
+	atomic.AddInt64(&fileProgress.bytesRead, int64(n))
+	return n, err
+}
+
+// lineCounter counts the lines read from reader for fileProgress.
+type lineCounter struct {
+	fileProgress *fileProgress
+	reader       io.Reader
+}
+
+// countLines returns reader counting the lines read from it. For a gzip
+// compressed file reader is the decompressed stream, so the lines of the
+// log are counted instead of the newlines in the compressed bytes.
+func (fileProgress *fileProgress) countLines(reader io.Reader) io.Reader {
+	return &lineCounter{fileProgress: fileProgress, reader: reader}
+}
+
+// Read reads from the reader and counts the lines read.
+func (counter *lineCounter) Read(p []byte) (int, error) {
+	n, err := counter.reader.Read(p)
+	atomic.AddInt64(&counter.fileProgress.lines, int64(bytes.Count(p[:n], []byte{'\n'})))
+	return n, err
+}
+
+// advance counts a line of n bytes read without Read, e.g. from a memory
+// mapped file.
+func (fileProgress *fileProgress) advance(n int) {
+	atomic.AddInt64(&fileProgress.bytesRead, int64(n))
+	atomic.AddInt64(&fileProgress.lines, 1)
+}
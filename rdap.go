@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Default RDAP server, rdap.org redirects to the registry of the address.
+// The IP address is appended.
+const defaultRDAPServer = "https://rdap.org/ip/"
+
+// rdapNetwork contains the registration data of the network of an IP
+// address that is shown in the report.
+type rdapNetwork struct {
+	name       string
+	country    string
+	abuseEmail string
+}
+
+// rdapResponse contains the fields of an RDAP IP network response that
+// ufwLogReader uses.
+type rdapResponse struct {
+	Name     string       `json:"name"`
+	Country  string       `json:"country"`
+	Entities []rdapEntity `json:"entities"`
+}
+
+// rdapEntity is a contact of an RDAP response. Entities can contain other
+// entities, e.g. the abuse contact of the registrant.
+type rdapEntity struct {
+	Roles      []string          `json:"roles"`
+	VCardArray []json.RawMessage `json:"vcardArray"`
+	Entities   []rdapEntity      `json:"entities"`
+}
+
+// lookupRDAP looks up the network of every IP address at the RDAP server.
+// IP addresses that could not be looked up, or for which the budget ran
+// out, are left out of the result.
+func lookupRDAP(ipAddresses []string, server string, budget *enrichmentBudget, timeout time.Duration) map[string]rdapNetwork {
+	client := &http.Client{Timeout: timeout}
+	networks := make(map[string]rdapNetwork)
+	for _, ipAddress := range ipAddresses {
+		if !budget.take() {
+			continue
+		}
+		network, err := queryRDAP(client, server, ipAddress)
+		if err != nil {
+			continue
+		}
+		networks[ipAddress] = network
+	}
+	return networks
+}
+
+// queryRDAP looks up a single IP address.
+func queryRDAP(client *http.Client, server string, ipAddress string) (rdapNetwork, error) {
+	request, err := http.NewRequest("GET", server+ipAddress, nil)
+	if err != nil {
+		return rdapNetwork{}, err
+	}
+	request.Header.Set("Accept", "application/rdap+json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return rdapNetwork{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return rdapNetwork{}, fmt.Errorf("rdap: %s: %s", ipAddress, response.Status)
+	}
+
+	var result rdapResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return rdapNetwork{}, err
+	}
+	return rdapNetwork{name: result.Name, country: result.Country, abuseEmail: abuseEmail(result.Entities)}, nil
+}
+
+// abuseEmail returns the email address of the first entity with the abuse
+// role, searching nested entities too.
+func abuseEmail(entities []rdapEntity) string {
+	for _, entity := range entities {
+		for _, role := range entity.Roles {
+			if role != "abuse" {
+				continue
+			}
+			if email := vCardEmail(entity.VCardArray); email != "" {
+				return email
+			}
+		}
+		if email := abuseEmail(entity.Entities); email != "" {
+			return email
+		}
+	}
+	return ""
+}
+
+// vCardEmail returns the email property of a jCard, e.g.
+// ["vcard", [["email", {}, "text", "abuse@example.com"]]].
+func vCardEmail(vCardArray []json.RawMessage) string {
+	if len(vCardArray) < 2 {
+		return ""
+	}
+	var properties [][]interface{}
+	if err := json.Unmarshal(vCardArray[1], &properties); err != nil {
+		return ""
+	}
+	for _, property := range properties {
+		if len(property) < 4 || property[0] != "email" {
+			continue
+		}
+		if email, ok := property[3].(string); ok {
+			return email
+		}
+	}
+	return ""
+}
+
+// String formats the network on a single line, e.g.
+// "EXAMPLE-NET (NL), abuse: abuse@example.com".
+func (network rdapNetwork) String() string {
+	text := network.name
+	if network.country != "" {
+		text += " (" + network.country + ")"
+	}
+	if network.abuseEmail != "" {
+		text += ", abuse: " + network.abuseEmail
+	}
+	return strings.TrimPrefix(text, " ")
+}
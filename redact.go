@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// sinkNames are the sinks that can be configured in the sinks section of
+// the configuration file.
+var sinkNames = map[string]bool{
+	"elasticsearch": true,
+	"loki":          true,
+	"influx":        true,
+	"metrics":       true,
+	"dump":          true,
+}
+
+// sinkConfig configures a sink in the configuration file. Redact maps the
+// fields of the events to the action applied before they are shipped:
+// drop removes the field, mask replaces an address by its /24 or /48
+// network and hash pseudonymizes it like -anonymize hash with Key. When
+// Tags is set only the events with one of the tags are shipped.
+type sinkConfig struct {
+	Redact map[string]string `json:"redact"`
+	Key    string            `json:"key"`
+	Tags   []string          `json:"tags"`
+
+	redaction *redaction
+}
+
+// redactionFields are the event fields that can be redacted, and whether
+// they are addresses that can be masked and hashed.
+var redactionFields = map[string]bool{
+	"source":      true,
+	"destination": true,
+	"port":        false,
+	"protocol":    false,
+	"flags":       false,
+}
+
+// redaction applies the redaction rules of a sink to its events.
+type redaction struct {
+	actions map[string]string
+	mask    *anonymizer
+	hash    *anonymizer
+}
+
+// compile validates the sink configuration and prepares its redaction.
+func (sinkConfig *sinkConfig) compile() error {
+	if len(sinkConfig.Redact) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(sinkConfig.Redact))
+	for field := range sinkConfig.Redact {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	redaction := &redaction{actions: sinkConfig.Redact, mask: &anonymizer{}}
+	for _, field := range fields {
+		address, ok := redactionFields[field]
+		if !ok {
+			return fmt.Errorf("unknown field %q, use source, destination, port, protocol or flags", field)
+		}
+		switch action := sinkConfig.Redact[field]; {
+		case action == "drop":
+		case (action == "mask" || action == "hash") && address:
+		case action == "mask" || action == "hash":
+			return fmt.Errorf("%s: %s only applies to addresses, use drop", field, action)
+		default:
+			return fmt.Errorf("%s: unknown action %q, use drop, mask or hash", field, action)
+		}
+		if sinkConfig.Redact[field] == "hash" && redaction.hash == nil {
+			var err error
+			redaction.hash, err = newAnonymizer("hash", sinkConfig.Key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	sinkConfig.redaction = redaction
+	return nil
+}
+
+// apply returns a redacted copy of event. A nil redaction returns event.
+func (redaction *redaction) apply(event *sinkEvent) *sinkEvent {
+	if redaction == nil {
+		return event
+	}
+	redacted := *event
+	redacted.source = redaction.address("source", event.source)
+	redacted.destination = redaction.address("destination", event.destination)
+	if redaction.drops("port") {
+		redacted.port = ""
+	}
+	if redaction.drops("protocol") {
+		redacted.protocol = ""
+	}
+	if redaction.drops("flags") {
+		redacted.flags = ""
+	}
+	return &redacted
+}
+
+// redacts reports whether field is redacted in any way. A nil redaction
+// redacts nothing.
+func (redaction *redaction) redacts(field string) bool {
+	return redaction != nil && redaction.actions[field] != ""
+}
+
+// drops reports whether field is dropped. A nil redaction drops nothing.
+func (redaction *redaction) drops(field string) bool {
+	return redaction != nil && redaction.actions[field] == "drop"
+}
+
+// address returns the redacted value of the address field.
+func (redaction *redaction) address(field string, address string) string {
+	if redaction == nil || address == "" {
+		return address
+	}
+	switch redaction.actions[field] {
+	case "drop":
+		return ""
+	case "mask":
+		return redaction.mask.anonymize(address)
+	case "hash":
+		return redaction.hash.anonymize(address)
+	}
+	return address
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+// parseSafely parses line with parse. A parser panicking on a malformed
+// line, like a truncated line or corrupted bytes, doesn't stop the scan,
+// the line is reported as malformed instead.
+func parseSafely(parse lineParser, line []byte, entry *logEntry) (request bool, malformed bool) {
+	defer func() {
+		if recover() != nil {
+			request, malformed = false, true
+		}
+	}()
+	return parse(line, entry), false
+}
+
+// rejectedLine is a line that wasn't counted as a request, with its line
+// number in the file.
+type rejectedLine struct {
+	number int
+	text   []byte
+}
+
+// rejectsWriter writes the rejected lines to the -rejects file as
+// "file:line: text", for inspection.
+type rejectsWriter struct {
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// newRejectsWriter creates filename and returns a writer of rejected lines
+// to it.
+func newRejectsWriter(filename string) (*rejectsWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &rejectsWriter{file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// write writes the rejected lines of the file called filename.
+func (rejects *rejectsWriter) write(filename string, rejected []rejectedLine) {
+	for _, line := range rejected {
+		fmt.Fprintf(rejects.writer, "%s:%d: %s\n", filename, line.number, line.text)
+	}
+}
+
+// close writes the buffered lines and closes the file.
+func (rejects *rejectsWriter) close() error {
+	err := rejects.writer.Flush()
+	if closeErr := rejects.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
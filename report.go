@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// report contains the aggregated requests and the enrichment data of the IP
+// addresses, ipAddresses is the order in which they are reported.
+type report struct {
+	ipPortMapMap  *ipPortMapMap
+	ipAddresses   []string
+	labels        *labelTable
+	services      serviceTable
+	dnsblListings map[string][]string
+	greyNoise     map[string]string
+	pdnsDomains   map[string][]string
+	rdapNetworks  map[string]rdapNetwork
+	probeResults  map[string]string
+	sparklines    bool
+	human         bool
+	firstActivity time.Time
+	lastActivity  time.Time
+
+	severityThresholds [2]int
+}
+
+// Label of the requests of log lines without a source IP address. They are
+// not included in the totals.
+const unknownSourceLabel = "Requests without a source IP address"
+
+// reportedIPAddresses returns the IP addresses that are shown in the report,
+// those with more than one request.
+func (report *report) reportedIPAddresses() []string {
+	var ipAddresses []string
+	for _, ipAddress := range report.ipAddresses {
+		if report.ipPortMapMap.ipPortMapMap[ipAddress].amountOfRequests > 1 {
+			ipAddresses = append(ipAddresses, ipAddress)
+		}
+	}
+	return ipAddresses
+}
+
+// totals returns the total amount of requests and the amount of requests
+// per port and per destination IP address of the reported IP addresses.
+func (report *report) totals() (int, map[string]int, map[string]int) {
+	totalRequests := 0
+	portRequests := make(map[string]int)
+	destinationRequests := make(map[string]int)
+	for _, ipAddress := range report.reportedIPAddresses() {
+		ipPortMapStruct := report.ipPortMapMap.ipPortMapMap[ipAddress]
+		totalRequests += ipPortMapStruct.amountOfRequests
+		for portNumber, amount := range ipPortMapStruct.portCounts() {
+			portRequests[portNumber] += amount
+		}
+		for destination, amount := range ipPortMapStruct.destinationCounts() {
+			destinationRequests[destination] += amount
+		}
+	}
+	return totalRequests, portRequests, destinationRequests
+}
+
+// printText prints the report in the plain text format.
+func (report *report) printText(w io.Writer) {
+	for _, ipAddress := range report.reportedIPAddresses() {
+		ipPortMapStruct := report.ipPortMapMap.ipPortMapMap[ipAddress]
+		fmt.Fprintf(w, "IP: %s\tAmount of requests: %s", report.labels.withLabel(ipAddress), report.count(ipPortMapStruct.amountOfRequests))
+		if report.sparklines {
+			fmt.Fprintf(w, "\t[%s]", sparkline(ipPortMapStruct.activity, report.firstActivity, report.lastActivity))
+		}
+		fmt.Fprintf(w, "\n\n")
+		if len(ipPortMapStruct.hosts) > 0 {
+			fmt.Fprintf(w, "\tUnique hosts: %s\n\n", report.count(len(ipPortMapStruct.hosts)))
+		}
+		if ipPortMapStruct.retransmissions > 0 {
+			fmt.Fprintf(w, "\tRetransmissions: %s\n\n", report.count(ipPortMapStruct.retransmissions))
+		}
+		if len(ipPortMapStruct.tags) > 0 {
+			fmt.Fprintf(w, "\tTags: %s\n\n", amountList(ipPortMapStruct.tags, 0))
+		}
+		if classification, ok := report.greyNoise[ipAddress]; ok {
+			fmt.Fprintf(w, "\tGreyNoise: %s\n\n", classification)
+		}
+		if network, ok := report.rdapNetworks[ipAddress]; ok {
+			fmt.Fprintf(w, "\tNetwork: %s\n\n", network)
+		}
+		if result, ok := report.probeResults[ipAddress]; ok {
+			fmt.Fprintf(w, "\tProbe: %s\n\n", result)
+		}
+		if domains := report.pdnsDomains[ipAddress]; len(domains) > 0 {
+			fmt.Fprintf(w, "\tRecent domains: %s\n\n", strings.Join(domains, ", "))
+		}
+		if zones := report.dnsblListings[ipAddress]; len(zones) > 0 {
+			fmt.Fprintf(w, "\tListed on: %s\n\n", strings.Join(zones, ", "))
+		}
+
+		portCounts := ipPortMapStruct.portCounts()
+		ports := newTextTable("\t", []string{"Port Number", "Amount"}, 1)
+		for _, portNumber := range sortedByAmount(portCounts, 0) {
+			ports.add(report.services.withService(portNumber), report.count(portCounts[portNumber]))
+		}
+		ports.write(w)
+
+		fmt.Fprintln(w)
+		destinationCounts := ipPortMapStruct.destinationCounts()
+		destinations := newTextTable("\t", []string{"Destination IP", "Amount"}, 1)
+		for _, destination := range sortedByAmount(destinationCounts, 0) {
+			destinations.add(report.labels.withLabel(destination), report.count(destinationCounts[destination]))
+		}
+		destinations.write(w)
+
+		if len(ipPortMapStruct.tcpFlags) > 0 {
+			fmt.Fprintln(w)
+			flagCounts := ipPortMapStruct.flagCounts()
+			flags := newTextTable("\t", []string{"TCP Flags", "Amount"}, 1)
+			for _, flagSet := range sortedByAmount(flagCounts, 0) {
+				flags.add(flagSet, report.count(flagCounts[flagSet]))
+			}
+			flags.write(w)
+		}
+		fmt.Fprintln(w)
+	}
+
+	totalRequests, portRequests, destinationRequests := report.totals()
+	fmt.Fprintf(w, "\n\nTotal amount of requests: %s\n", report.count(totalRequests))
+	fmt.Fprintf(w, "Most requestsed port: %s\n", report.services.withService(getMostRequestedPort(portRequests)))
+	for _, gap := range coverageGaps(report.ipPortMapMap.hourlyRequests) {
+		fmt.Fprintf(w, "Coverage gap: %s\n", gap)
+	}
+
+	fmt.Fprintln(w)
+	destinations := newTextTable("", []string{"Destination IP", "Amount of requests"}, 1)
+	for _, destination := range sortedByAmount(destinationRequests, 0) {
+		destinations.add(report.labels.withLabel(destination), report.count(destinationRequests[destination]))
+	}
+	destinations.write(w)
+
+	unknownSource := report.ipPortMapMap.unknownSource
+	if unknownSource.amountOfRequests > 0 {
+		fmt.Fprintf(w, "\n%s: %s\n", unknownSourceLabel, report.count(unknownSource.amountOfRequests))
+		fmt.Fprintln(w)
+		portCounts := unknownSource.portCounts()
+		ports := newTextTable("\t", []string{"Port Number", "Amount"}, 1)
+		for _, portNumber := range sortedByAmount(portCounts, 0) {
+			ports.add(report.services.withService(portNumber), report.count(portCounts[portNumber]))
+		}
+		ports.write(w)
+	}
+
+	report.printGroupStats(w)
+}
+
+// printMarkdown prints the report as Markdown tables of the top IP
+// addresses, top ports and top destination addresses followed by the
+// totals, ready to be pasted in a wiki page or an issue.
+func (report *report) printMarkdown(w io.Writer, limit int) {
+	totalRequests, portRequests, destinationRequests := report.totals()
+
+	fmt.Fprintf(w, "# ufw report\n\n")
+	fmt.Fprintf(w, "## Top IP addresses\n\n")
+	fmt.Fprintf(w, "| IP address | Requests | Hosts | Ports | Notes |\n")
+	fmt.Fprintf(w, "| --- | ---: | ---: | --- | --- |\n")
+	ipAddresses := report.reportedIPAddresses()
+	if limit > 0 && len(ipAddresses) > limit {
+		ipAddresses = ipAddresses[:limit]
+	}
+	for _, ipAddress := range ipAddresses {
+		ipPortMapStruct := report.ipPortMapMap.ipPortMapMap[ipAddress]
+		portCounts := ipPortMapStruct.portCounts()
+		var ports []string
+		for _, portNumber := range sortedByAmount(portCounts, 5) {
+			ports = append(ports, fmt.Sprintf("%s: %d", report.services.withService(portNumber), portCounts[portNumber]))
+		}
+		fmt.Fprintf(w, "| %s | %d | %d | %s | %s |\n", markdownEscape(report.labels.withLabel(ipAddress)),
+			ipPortMapStruct.amountOfRequests, uniqueHosts(ipPortMapStruct), strings.Join(ports, ", "), markdownEscape(report.notes(ipAddress)))
+	}
+
+	fmt.Fprintf(w, "\n## Top ports\n\n")
+	fmt.Fprintf(w, "| Port | Requests |\n")
+	fmt.Fprintf(w, "| --- | ---: |\n")
+	for _, portNumber := range sortedByAmount(portRequests, limit) {
+		fmt.Fprintf(w, "| %s | %d |\n", report.services.withService(portNumber), portRequests[portNumber])
+	}
+
+	fmt.Fprintf(w, "\n## Top destination addresses\n\n")
+	fmt.Fprintf(w, "| Destination IP | Requests |\n")
+	fmt.Fprintf(w, "| --- | ---: |\n")
+	for _, destination := range sortedByAmount(destinationRequests, limit) {
+		fmt.Fprintf(w, "| %s | %d |\n", markdownEscape(report.labels.withLabel(destination)), destinationRequests[destination])
+	}
+
+	fmt.Fprintf(w, "\n## Totals\n\n")
+	fmt.Fprintf(w, "| | |\n")
+	fmt.Fprintf(w, "| --- | --- |\n")
+	fmt.Fprintf(w, "| Total amount of requests | %d |\n", totalRequests)
+	fmt.Fprintf(w, "| Source IP addresses | %d |\n", len(report.reportedIPAddresses()))
+	fmt.Fprintf(w, "| Most requested port | %s |\n", report.services.withService(getMostRequestedPort(portRequests)))
+	if gaps := coverageGaps(report.ipPortMapMap.hourlyRequests); len(gaps) > 0 {
+		fmt.Fprintf(w, "| Coverage gaps | %s |\n", joinGaps(gaps))
+	}
+	if unknownSource := report.ipPortMapMap.unknownSource; unknownSource.amountOfRequests > 0 {
+		fmt.Fprintf(w, "| %s | %d |\n", unknownSourceLabel, unknownSource.amountOfRequests)
+	}
+}
+
+// uniqueHosts returns the amount of IP addresses counted in
+// ipPortMapStruct, which is more than one for aggregated subnets.
+func uniqueHosts(ipPortMapStruct *ipPortMapStruct) int {
+	if len(ipPortMapStruct.hosts) > 0 {
+		return len(ipPortMapStruct.hosts)
+	}
+	return 1
+}
+
+// count formats an amount for the text report, humanized when requested.
+func (report *report) count(amount int) string {
+	return formatCount(amount, report.human)
+}
+
+// notes returns the enrichment data of ipAddress on a single line.
+func (report *report) notes(ipAddress string) string {
+	var notes []string
+	if classification, ok := report.greyNoise[ipAddress]; ok {
+		notes = append(notes, "GreyNoise: "+classification)
+	}
+	if network, ok := report.rdapNetworks[ipAddress]; ok {
+		notes = append(notes, "network: "+network.String())
+	}
+	if result, ok := report.probeResults[ipAddress]; ok {
+		notes = append(notes, "probe: "+result)
+	}
+	if zones := report.dnsblListings[ipAddress]; len(zones) > 0 {
+		notes = append(notes, "listed on "+strings.Join(zones, ", "))
+	}
+	if domains := report.pdnsDomains[ipAddress]; len(domains) > 0 {
+		notes = append(notes, "domains: "+strings.Join(domains, ", "))
+	}
+	return strings.Join(notes, "; ")
+}
+
+// markdownEscape escapes the characters that would break a Markdown table
+// cell.
+func markdownEscape(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(text)
+}
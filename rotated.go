@@ -0,0 +1,66 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rotatedFile is a rotated log file with the number logrotate gave it,
+// e.g. 2 for ufw.log.2.gz.
+type rotatedFile struct {
+	filename string
+	number   int
+}
+
+// includeRotated returns the files with the files logrotate rotated them
+// into, like ufw.log.1 and ufw.log.2.gz for ufw.log, in chronological
+// order: the oldest rotated file first and the file itself last. Files
+// that are passed more than once are only returned once.
+func includeRotated(files []string) ([]string, error) {
+	var included []string
+	seen := make(map[string]bool)
+	add := func(filename string) {
+		if !seen[filename] {
+			seen[filename] = true
+			included = append(included, filename)
+		}
+	}
+	for _, file := range files {
+		matches, err := filepath.Glob(globEscape(file) + ".*")
+		if err != nil {
+			return nil, err
+		}
+		var rotated []rotatedFile
+		for _, match := range matches {
+			suffix := strings.TrimSuffix(strings.TrimPrefix(match, file+"."), ".gz")
+			number, err := strconv.Atoi(suffix)
+			if err != nil || number < 1 {
+				continue
+			}
+			rotated = append(rotated, rotatedFile{filename: match, number: number})
+		}
+		sort.Slice(rotated, func(i, j int) bool {
+			return rotated[i].number > rotated[j].number
+		})
+		for _, rotatedFile := range rotated {
+			add(rotatedFile.filename)
+		}
+		add(file)
+	}
+	return included, nil
+}
+
+// globEscape escapes the characters of filename that have a meaning in
+// filepath.Match patterns.
+func globEscape(filename string) string {
+	var escaped strings.Builder
+	for _, c := range filename {
+		if strings.ContainsRune(`*?[\`, c) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(c)
+	}
+	return escaped.String()
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// scanFailure describes a file that could not be read completely. lines is
+// the amount of lines read before err, the file was partially read when it
+// is larger than zero.
+type scanFailure struct {
+	filename string
+	lines    int
+	err      error
+}
+
+// printScanFailures writes a summary of the files that failed or were
+// partially read to w.
+func printScanFailures(w io.Writer, failures []scanFailure, files int) {
+	fmt.Fprintf(w, "Failed to read %d of %d files:\n", len(failures), files)
+	for _, failure := range failures {
+		if failure.lines > 0 {
+			fmt.Fprintf(w, "\t%s: partially read, stopped after %d lines: %v\n", failure.filename, failure.lines, failure.err)
+		} else {
+			fmt.Fprintf(w, "\t%s: %v\n", failure.filename, failure.err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// selftestFiles contains the fixture logs and the golden outputs of the
+// selftest command. Every fixture selftest/fixtures/NAME.log, or the gzip
+// compressed NAME.log.gz, has golden files selftest/golden/NAME.EXT, one
+// for every output in selftestOutputs.
+//
+//go:embed selftest/fixtures selftest/golden
+var selftestFiles embed.FS
+
+// selftestOutputs renders the outputs that are compared against the golden
+// files, keyed by file extension.
+var selftestOutputs = map[string]func(w io.Writer, report *report){
+	"txt":     func(w io.Writer, report *report) { report.printText(w) },
+	"md":      func(w io.Writer, report *report) { report.printMarkdown(w, 20) },
+	"heatmap": func(w io.Writer, report *report) { printHeatmap(w, report.ipPortMapMap.portHours, 20, false) },
+}
+
+// selftestInputFormats are the input formats of the fixtures that can't be
+// read with the auto input format, keyed by fixture name.
+var selftestInputFormats = map[string]string{
+	"pf":      "pf",
+	"windows": "windows",
+}
+
+// runSelftest runs the full pipeline against the embedded fixture logs and
+// compares the outputs with the golden files. With -write the outputs are
+// written to a directory instead, to update the golden files after an
+// intended change. It returns the exit status.
+func runSelftest(args []string) int {
+	flags := flag.NewFlagSet("selftest", flag.ExitOnError)
+	writeDir := flags.String("write", "", "write the outputs to `directory` instead of comparing them")
+	flags.Parse(args)
+
+	fixtures, err := selftestFiles.ReadDir("selftest/fixtures")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	extensions := make([]string, 0, len(selftestOutputs))
+	for extension := range selftestOutputs {
+		extensions = append(extensions, extension)
+	}
+	sort.Strings(extensions)
+
+	failures := 0
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(strings.TrimSuffix(fixture.Name(), ".gz"), ".log")
+		log, err := selftestFiles.ReadFile(path.Join("selftest/fixtures", fixture.Name()))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		file, err := newLogFile(fixture.Name(), bytes.NewReader(log))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		inputFormat, ok := selftestInputFormats[name]
+		if !ok {
+			inputFormat = "auto"
+		}
+		parse, err := inputFormatParser(parseFields, inputFormat)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		ipPortMapMap := newIPPortMapMap()
+		if _, err := scanFile(file, ipPortMapMap, parse, defaultMaxLineBytes); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		report := &report{ipPortMapMap: ipPortMapMap, ipAddresses: ipPortMapMap.topIPAddresses(0), services: embeddedServices}
+
+		for _, extension := range extensions {
+			goldenName := name + "." + extension
+			var output bytes.Buffer
+			selftestOutputs[extension](&output, report)
+
+			if *writeDir != "" {
+				if err := os.WriteFile(filepath.Join(*writeDir, goldenName), output.Bytes(), 0644); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					return 1
+				}
+				continue
+			}
+
+			golden, err := selftestFiles.ReadFile(path.Join("selftest/golden", goldenName))
+			if err != nil {
+				fmt.Printf("FAIL\t%s: %v\n", goldenName, err)
+				failures++
+				continue
+			}
+			if line, ok := firstDifference(output.Bytes(), golden); !ok {
+				fmt.Printf("FAIL\t%s: line %d differs\n", goldenName, line)
+				failures++
+				continue
+			}
+			fmt.Printf("ok\t%s\n", goldenName)
+		}
+	}
+
+	if failures > 0 {
+		fmt.Printf("%d of the outputs differ from the golden files.\n", failures)
+		return 1
+	}
+	return 0
+}
+
+// firstDifference compares output with golden line by line. It returns the
+// number of the first line that differs and false, or 0 and true when both
+// are equal.
+func firstDifference(output []byte, golden []byte) (int, bool) {
+	outputLines := strings.Split(string(output), "\n")
+	goldenLines := strings.Split(string(golden), "\n")
+	for i := 0; i < len(outputLines) || i < len(goldenLines); i++ {
+		if i >= len(outputLines) || i >= len(goldenLines) || outputLines[i] != goldenLines[i] {
+			return i + 1, false
+		}
+	}
+	return 0, true
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// serviceTable maps port numbers to service names, e.g. "23" to "telnet".
+type serviceTable map[string]string
+
+// embeddedServices are the service names of ports that are commonly probed.
+// They take precedence over /etc/services, which uses less familiar names
+// for some of them such as ms-wbt-server for rdp.
+var embeddedServices = serviceTable{
+	"20": "ftp-data", "21": "ftp", "22": "ssh", "23": "telnet",
+	"25": "smtp", "53": "domain", "67": "bootps", "69": "tftp",
+	"80": "http", "110": "pop3", "111": "sunrpc", "123": "ntp",
+	"135": "msrpc", "137": "netbios-ns", "138": "netbios-dgm",
+	"139": "netbios-ssn", "143": "imap", "161": "snmp", "389": "ldap",
+	"443": "https", "445": "smb", "465": "smtps", "500": "isakmp",
+	"587": "submission", "993": "imaps", "995": "pop3s",
+	"1080": "socks", "1433": "mssql", "1521": "oracle", "1723": "pptp",
+	"1883": "mqtt", "1900": "ssdp", "2323": "telnet-alt",
+	"2375": "docker", "3306": "mysql", "3389": "rdp", "5060": "sip",
+	"5432": "postgresql", "5900": "vnc", "5985": "winrm",
+	"6379": "redis", "7547": "cwmp", "8080": "http-alt",
+	"8443": "https-alt", "9200": "elasticsearch", "11211": "memcached",
+	"27017": "mongodb",
+}
+
+// loadServices returns the embedded service names, extended with the TCP
+// and UDP services in the services file, usually /etc/services. A missing
+// services file is not an error.
+func loadServices(filename string) serviceTable {
+	services := readServicesFile(filename)
+	for port, name := range embeddedServices {
+		services[port] = name
+	}
+	return services
+}
+
+// readServicesFile reads the service names of a services file. TCP names
+// take precedence over UDP names of the same port.
+func readServicesFile(filename string) serviceTable {
+	services := make(serviceTable)
+	file, err := os.Open(filename)
+	if err != nil {
+		return services
+	}
+	defer file.Close()
+
+	udpServices := make(serviceTable)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		portProtocol := strings.SplitN(fields[1], "/", 2)
+		if len(portProtocol) != 2 {
+			continue
+		}
+		switch portProtocol[1] {
+		case "tcp":
+			services[portProtocol[0]] = fields[0]
+		case "udp":
+			udpServices[portProtocol[0]] = fields[0]
+		}
+	}
+	for port, name := range udpServices {
+		if _, ok := services[port]; !ok {
+			services[port] = name
+		}
+	}
+	return services
+}
+
+// withService appends the service name of portNumber between parentheses,
+// if it is known.
+func (services serviceTable) withService(portNumber string) string {
+	if name, ok := services[portNumber]; ok {
+		return portNumber + " (" + name + ")"
+	}
+	return portNumber
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Severities of source IP addresses, from low to high.
+const (
+	severityLow    = "low"
+	severityMedium = "medium"
+	severityHigh   = "high"
+)
+
+// parseSeverityThresholds parses "medium,high", the amounts of requests at
+// which an IP address gets a medium and a high severity.
+func parseSeverityThresholds(list string) ([2]int, error) {
+	var thresholds [2]int
+	elements := splitList(list)
+	if len(elements) != 2 {
+		return thresholds, fmt.Errorf("invalid severity thresholds %q, use medium,high", list)
+	}
+	for i, element := range elements {
+		threshold, err := strconv.Atoi(element)
+		if err != nil || threshold < 1 {
+			return thresholds, fmt.Errorf("invalid severity threshold %q", element)
+		}
+		thresholds[i] = threshold
+	}
+	if thresholds[0] > thresholds[1] {
+		return thresholds, fmt.Errorf("medium severity threshold %d is above the high threshold %d", thresholds[0], thresholds[1])
+	}
+	return thresholds, nil
+}
+
+// severity rates an IP address by its amount of requests and enrichment
+// data. IP addresses GreyNoise classified as malicious or that are listed
+// on a DNSBL are rated one level higher, benign internet scanners are
+// always rated low.
+func (report *report) severity(ipAddress string) string {
+	if report.greyNoise[ipAddress] == greyNoiseBenign {
+		return severityLow
+	}
+
+	level := 0
+	amount := report.ipPortMapMap.ipPortMapMap[ipAddress].amountOfRequests
+	if amount >= report.severityThresholds[1] {
+		level = 2
+	} else if amount >= report.severityThresholds[0] {
+		level = 1
+	}
+	if report.greyNoise[ipAddress] == greyNoiseMalicious || len(report.dnsblListings[ipAddress]) > 0 {
+		level++
+	}
+
+	switch {
+	case level >= 2:
+		return severityHigh
+	case level == 1:
+		return severityMedium
+	default:
+		return severityLow
+	}
+}
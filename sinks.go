@@ -0,0 +1,49 @@
+package main
+
+import "time"
+
+// sinkEvent is a counted request as it is shipped to the event sinks. The
+// source is the counted source, so it is anonymized when -anonymize is set.
+type sinkEvent struct {
+	timestamp   time.Time
+	action      string
+	inInterface string
+	source      string
+	destination string
+	protocol    string
+	port        string
+	flags       string
+	tags        []string
+}
+
+// eventSink ships every counted request to an external system. Sinks may
+// buffer events, flush sends the buffered events and returns the first
+// error since the previous flush.
+type eventSink interface {
+	writeEvent(event *sinkEvent)
+	flush() error
+}
+
+// flushSinks flushes every sink and returns their errors.
+func flushSinks(sinks []eventSink) []error {
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// taggedSink only writes the events with one of its tags to sink.
+type taggedSink struct {
+	eventSink
+	tags []string
+}
+
+// writeEvent writes event when it has one of the tags of the sink.
+func (sink *taggedSink) writeEvent(event *sinkEvent) {
+	if hasTag(event.tags, sink.tags) {
+		sink.eventSink.writeEvent(event)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"time"
+)
+
+// Number of characters of a sparkline.
+const sparklineWidth = 24
+
+// sparklineLevels are the characters used for increasing activity. Periods
+// without any activity are shown as a space.
+var sparklineLevels = []rune("▁▂▃▄▅▆▇█")
+
+// activityWindow returns the first and last minute with activity of all IP
+// addresses.
+func (ipPortMapMap *ipPortMapMap) activityWindow() (time.Time, time.Time) {
+	var first, last time.Time
+	for _, ipPortMapStruct := range ipPortMapMap.ipPortMapMap {
+		for minute := range ipPortMapStruct.activity {
+			if first.IsZero() || minute.Before(first) {
+				first = minute
+			}
+			if minute.After(last) {
+				last = minute
+			}
+		}
+	}
+	return first, last
+}
+
+// sparkline renders the activity per minute between first and last as a
+// small chart of sparklineWidth characters, scaled to its own busiest
+// period.
+func sparkline(activity map[time.Time]int, first time.Time, last time.Time) string {
+	slots := make([]int, sparklineWidth)
+	window := last.Sub(first) + time.Minute
+	for minute, amount := range activity {
+		slot := int(minute.Sub(first) * sparklineWidth / window)
+		if slot >= 0 && slot < sparklineWidth {
+			slots[slot] += amount
+		}
+	}
+
+	highest := 0
+	for _, amount := range slots {
+		if amount > highest {
+			highest = amount
+		}
+	}
+
+	line := make([]rune, sparklineWidth)
+	for i, amount := range slots {
+		if amount == 0 {
+			line[i] = ' '
+			continue
+		}
+		line[i] = sparklineLevels[(amount*len(sparklineLevels)-1)/highest]
+	}
+	return string(line)
+}
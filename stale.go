@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// staleMonitor tracks when the followed log files last received a line, to
+// alert when one stays silent for longer than after. A silent log usually
+// means a broken collector or disabled ufw logging, not a quiet network.
+type staleMonitor struct {
+	after time.Duration
+	last  map[string]time.Time
+	stale map[string]bool
+}
+
+// newStaleMonitor returns a monitor of filenames, counting their silence
+// from started.
+func newStaleMonitor(filenames []string, after time.Duration, started time.Time) *staleMonitor {
+	monitor := &staleMonitor{after: after, last: make(map[string]time.Time), stale: make(map[string]bool)}
+	for _, filename := range filenames {
+		monitor.last[filename] = started
+	}
+	return monitor
+}
+
+// seen records a line of filename at now. It returns a message when the
+// file was stale, to report that it receives lines again.
+func (monitor *staleMonitor) seen(filename string, now time.Time) string {
+	monitor.last[filename] = now
+	if !monitor.stale[filename] {
+		return ""
+	}
+	delete(monitor.stale, filename)
+	return fmt.Sprintf("%s receives lines again", filename)
+}
+
+// check returns a message for every file that became stale at now. Every
+// file is reported once until it receives lines again.
+func (monitor *staleMonitor) check(now time.Time) []string {
+	var messages []string
+	for filename, last := range monitor.last {
+		silence := now.Sub(last)
+		if silence <= monitor.after || monitor.stale[filename] {
+			continue
+		}
+		monitor.stale[filename] = true
+		messages = append(messages, fmt.Sprintf("%s received no lines for %s, is ufw logging still working?", filename, silence.Round(time.Second)))
+	}
+	return messages
+}
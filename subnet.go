@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net"
+)
+
+// subnetOf returns the network of ipAddress with the given prefix length as
+// CIDR, e.g. 45.155.205.0/24, using prefix4 for IPv4 and prefix6 for IPv6
+// addresses. A prefix of zero leaves addresses of that family unchanged.
+func subnetOf(ipAddress string, prefix4 int, prefix6 int) string {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return ipAddress
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		if prefix4 <= 0 || prefix4 > 32 {
+			return ipAddress
+		}
+		network := net.IPNet{IP: ip4.Mask(net.CIDRMask(prefix4, 32)), Mask: net.CIDRMask(prefix4, 32)}
+		return network.String()
+	}
+	if prefix6 <= 0 || prefix6 > 128 {
+		return ipAddress
+	}
+	network := net.IPNet{IP: ip.Mask(net.CIDRMask(prefix6, 128)), Mask: net.CIDRMask(prefix6, 128)}
+	return network.String()
+}
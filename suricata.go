@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The first signature ID given to a rule, the start of the range reserved
+// for local rules.
+const suricataFirstSID = 1000000
+
+// suricataSID is the signature ID and revision of the rule of a source
+// address. Msg is the message of the last revision, the revision is bumped
+// when it changes.
+type suricataSID struct {
+	SID int    `json:"sid"`
+	Rev int    `json:"rev"`
+	Msg string `json:"msg"`
+}
+
+// loadSuricataSIDs reads the signature IDs of earlier runs from filename.
+// A missing file has no signature IDs.
+func loadSuricataSIDs(filename string) (map[string]*suricataSID, error) {
+	sids := make(map[string]*suricataSID)
+	if filename == "" {
+		return sids, nil
+	}
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return sids, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &sids); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return sids, nil
+}
+
+// saveSuricataSIDs writes the signature IDs to filename.
+func saveSuricataSIDs(filename string, sids map[string]*suricataSID) error {
+	data, err := json.MarshalIndent(sids, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+// writeSuricataRules writes a Suricata/Snort drop rule for every source
+// address rated high severity. Addresses keep their signature ID in sids
+// across runs, the revision is bumped when the rule changes. New
+// addresses get the next free signature ID.
+func (report *report) writeSuricataRules(w io.Writer, sids map[string]*suricataSID) error {
+	nextSID := suricataFirstSID
+	for _, sid := range sids {
+		if sid.SID >= nextSID {
+			nextSID = sid.SID + 1
+		}
+	}
+
+	for _, ipAddress := range report.reportedIPAddresses() {
+		if report.severity(ipAddress) != severityHigh {
+			continue
+		}
+		// Anonymized addresses and the "other" entry can't be matched.
+		if _, err := parseNetwork(ipAddress); err != nil {
+			continue
+		}
+
+		msg := fmt.Sprintf("ufwLogReader offender %s, ports %s", ipAddress, suricataPorts(report.ipPortMapMap.ipPortMapMap[ipAddress].portCounts()))
+		sid := sids[ipAddress]
+		switch {
+		case sid == nil:
+			sid = &suricataSID{SID: nextSID, Rev: 1, Msg: msg}
+			sids[ipAddress] = sid
+			nextSID++
+		case sid.Msg != msg:
+			sid.Rev++
+			sid.Msg = msg
+		}
+		if _, err := fmt.Fprintf(w, "drop ip %s any -> $HOME_NET any (msg:\"%s\"; classtype:attempted-recon; sid:%d; rev:%d;)\n", ipAddress, msg, sid.SID, sid.Rev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// suricataPorts returns the top 5 ports in numerical order, so the message
+// of a rule only changes when the top ports do.
+func suricataPorts(ports map[string]int) string {
+	top := sortedByAmount(ports, 5)
+	sort.Slice(top, func(i, j int) bool {
+		first, _ := strconv.Atoi(top[i])
+		second, _ := strconv.Atoi(top[j])
+		return first < second
+	})
+	return strings.Join(top, ",")
+}
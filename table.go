@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// textTable is a table of the text output. Its columns are aligned with
+// text/tabwriter, so long IPv6 addresses and large counts don't break the
+// alignment.
+type textTable struct {
+	indent       string
+	header       []string
+	rightAligned map[int]bool
+	rows         [][]string
+}
+
+// newTextTable returns a table with the given column headers, every line
+// prefixed with indent. The columns at the rightAligned indexes, the
+// numbers, are aligned to the right.
+func newTextTable(indent string, header []string, rightAligned ...int) *textTable {
+	table := &textTable{indent: indent, header: header, rightAligned: make(map[int]bool)}
+	for _, column := range rightAligned {
+		table.rightAligned[column] = true
+	}
+	return table
+}
+
+// add adds a row with the given cells.
+func (table *textTable) add(cells ...string) {
+	table.rows = append(table.rows, cells)
+}
+
+// write writes the header and the rows to w. tabwriter.AlignRight aligns
+// every column to the right, including the addresses and names, so the
+// cells of the right aligned columns are padded to the width of their
+// widest cell instead.
+func (table *textTable) write(w io.Writer) {
+	rows := append([][]string{table.header}, table.rows...)
+	widths := make(map[int]int)
+	for _, row := range rows {
+		for i, cell := range row {
+			if table.rightAligned[i] {
+				widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+	writer := tabwriter.NewWriter(&buffer, 0, 8, 2, ' ', 0)
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			if table.rightAligned[i] {
+				cell = strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)) + cell
+			}
+			cells[i] = cell
+		}
+		fmt.Fprintln(writer, strings.Join(cells, "\t"))
+	}
+	writer.Flush()
+
+	for _, line := range strings.SplitAfter(buffer.String(), "\n") {
+		if line != "" {
+			fmt.Fprintf(w, "%s%s", table.indent, line)
+		}
+	}
+}
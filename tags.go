@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// tagRule attaches the tag Name to every request it matches. Ports are
+// destination ports or ranges like 6000-6010, Sources IP addresses or
+// CIDRs, Actions ufw actions like BLOCK. A request matches when it matches
+// one of the values of every field that is set, e.g.
+//
+//	{"name": "iot-ports", "ports": ["23", "2323", "7547"]}
+type tagRule struct {
+	Name       string   `json:"name"`
+	Ports      []string `json:"ports"`
+	Protocols  []string `json:"protocols"`
+	Sources    []string `json:"sources"`
+	Actions    []string `json:"actions"`
+	Interfaces []string `json:"interfaces"`
+
+	ports   []portRange
+	sources []*net.IPNet
+}
+
+// compile validates the rule and parses its ports and sources.
+func (rule *tagRule) compile() error {
+	if rule.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	for _, port := range rule.Ports {
+		bounds := strings.SplitN(port, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return fmt.Errorf("%s: invalid port %q", rule.Name, port)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+				return fmt.Errorf("%s: invalid port range %q", rule.Name, port)
+			}
+		}
+		rule.ports = append(rule.ports, portRange{port, first, last})
+	}
+	for _, source := range rule.Sources {
+		network, err := parseNetwork(source)
+		if err != nil {
+			return fmt.Errorf("%s: %v", rule.Name, err)
+		}
+		rule.sources = append(rule.sources, network)
+	}
+	return nil
+}
+
+// matches reports whether the rule matches entry.
+func (rule *tagRule) matches(entry *logEntry) bool {
+	if len(rule.ports) > 0 {
+		port, err := strconv.Atoi(entry.port)
+		matched := false
+		for _, portRange := range rule.ports {
+			if err == nil && port >= portRange.first && port <= portRange.last {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(rule.Protocols) > 0 && !containsFold(rule.Protocols, entry.protocol) {
+		return false
+	}
+	if len(rule.Actions) > 0 && !containsFold(rule.Actions, entry.action) {
+		return false
+	}
+	if len(rule.Interfaces) > 0 && !containsFold(rule.Interfaces, entry.inInterface) {
+		return false
+	}
+	if len(rule.sources) > 0 {
+		ip := net.ParseIP(entry.source)
+		matched := false
+		for _, network := range rule.sources {
+			if ip != nil && network.Contains(ip) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// containsFold reports whether values contains value, ignoring case.
+func containsFold(values []string, value string) bool {
+	for _, element := range values {
+		if strings.EqualFold(element, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagEntry returns the tags of the rules matching entry, every tag once.
+func tagEntry(rules []tagRule, entry *logEntry) []string {
+	var tags []string
+	for i := range rules {
+		if rules[i].matches(entry) && !containsFold(tags, rules[i].Name) {
+			tags = append(tags, rules[i].Name)
+		}
+	}
+	return tags
+}
+
+// hasTag reports whether tags contains one of wanted.
+func hasTag(tags []string, wanted []string) bool {
+	for _, tag := range tags {
+		if containsFold(wanted, tag) {
+			return true
+		}
+	}
+	return false
+}
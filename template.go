@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateFunctions are the functions available in a -template besides
+// the builtin ones.
+var templateFunctions = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// loadTemplate parses the -template file called filename.
+func loadTemplate(filename string) (*template.Template, error) {
+	return template.New(filepath.Base(filename)).Funcs(templateFunctions).ParseFiles(filename)
+}
+
+// writeTemplate renders the report through outputTemplate. It gets the same
+// data as the HTML report, see htmlReport.
+func (report *report) writeTemplate(w io.Writer, outputTemplate *template.Template) error {
+	return outputTemplate.Execute(w, report.htmlData())
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"time"
+)
+
+// Layout of the classic syslog timestamp at the start of a ufw log line,
+// e.g. "Dec 27 13:54:32".
+const syslogTimestampLayout = "Jan _2 15:04:05"
+
+// Syslog timestamps carry no year, they are parsed in yearlessYear until
+// yearInference sets their year. It is a leap year, so Feb 29 isn't turned
+// into Mar 1 before the year is known.
+const yearlessYear = 2000
+
+// logLocation is the time zone of timestamps without a zone, like syslog
+// timestamps. It is set with -tz for logs copied from servers in other
+// zones.
+var logLocation = time.Local
+
+// months maps the abbreviated month names of syslog timestamps to months.
+var months = map[string]time.Month{
+	"Jan": time.January, "Feb": time.February, "Mar": time.March,
+	"Apr": time.April, "May": time.May, "Jun": time.June,
+	"Jul": time.July, "Aug": time.August, "Sep": time.September,
+	"Oct": time.October, "Nov": time.November, "Dec": time.December,
+}
+
+// parseTimestamp parses the timestamp at the start of line into entry,
+// either a classic syslog timestamp or the ISO8601 timestamp of newer
+// rsyslog configurations and RFC5424 messages, see parseISOTimestamp.
+// Syslog timestamps are yearless, their year is inferred when the entry is
+// counted, see yearInference.
+func parseTimestamp(line []byte, entry *logEntry) {
+	if len(line) > 0 && (line[0] == '<' || line[0] >= '0' && line[0] <= '9') {
+		entry.timestamp, entry.hasTimestamp = parseISOTimestamp(line)
+		return
+	}
+	entry.timestamp, entry.hasTimestamp = parseSyslogTimestamp(line)
+	entry.yearless = entry.hasTimestamp
+}
+
+// parseSyslogTimestamp parses the classic syslog timestamp at the start of
+// line in yearlessYear. It is parsed by hand because time.Parse is a
+// considerable part of the time spent per line.
+func parseSyslogTimestamp(line []byte) (time.Time, bool) {
+	if len(line) < len(syslogTimestampLayout) {
+		return time.Time{}, false
+	}
+	month, ok := months[string(line[0:3])]
+	if !ok || line[3] != ' ' || line[6] != ' ' || line[9] != ':' || line[12] != ':' {
+		return time.Time{}, false
+	}
+
+	day := 0
+	if line[4] != ' ' {
+		day, ok = parseDigits(line[4:5])
+		if !ok {
+			return time.Time{}, false
+		}
+	}
+	lastDigit, ok := parseDigits(line[5:6])
+	hour, okHour := parseDigits(line[7:9])
+	minute, okMinute := parseDigits(line[10:12])
+	second, okSecond := parseDigits(line[13:15])
+	if !ok || !okHour || !okMinute || !okSecond {
+		return time.Time{}, false
+	}
+	day = day*10 + lastDigit
+	if day < 1 || day > 31 || hour > 23 || minute > 59 || second > 60 {
+		return time.Time{}, false
+	}
+	return time.Date(yearlessYear, month, day, hour, minute, second, 0, logLocation), true
+}
+
+// parseISOTimestamp parses the ISO8601 timestamp at the start of line, e.g.
+// "2026-12-27T13:54:32.123456+01:00" of the RSYSLOG_FileFormat template.
+// The "<PRI>VERSION " header of an RFC5424 message before the timestamp is
+// skipped. The timestamp keeps the time zone offset of the log.
+func parseISOTimestamp(line []byte) (time.Time, bool) {
+	if line[0] == '<' {
+		end := bytes.IndexByte(line, '>')
+		if end < 0 {
+			return time.Time{}, false
+		}
+		line = line[end+1:]
+		space := bytes.IndexByte(line, ' ')
+		if space < 0 {
+			return time.Time{}, false
+		}
+		if _, ok := parseDigits(line[:space]); !ok {
+			return time.Time{}, false
+		}
+		line = line[space+1:]
+	}
+	if len(line) < len("2006-01-02T15:04:05Z") || line[4] != '-' || line[10] != 'T' {
+		return time.Time{}, false
+	}
+	if space := bytes.IndexByte(line, ' '); space >= 0 {
+		line = line[:space]
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, string(line))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return timestamp, true
+}
+
+// parseDigits parses a non-negative decimal number.
+func parseDigits(digits []byte) (int, bool) {
+	number := 0
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		number = number*10 + int(c-'0')
+	}
+	return number, len(digits) > 0
+}
+
+// yearJump is how far a yearless timestamp has to go back to be taken as
+// the start of a new year, like Dec 31 followed by Jan 1, rather than
+// lines logged slightly out of order.
+const yearJump = 180 * 24 * time.Hour
+
+// yearInference infers the year of the yearless timestamps of a single
+// log, in the order of its lines. The first timestamp gets the year of
+// reference, usually the modification time of the file, or the year
+// before when it would be after reference. Every later timestamp that
+// goes back more than yearJump starts the next year, so logs spanning New
+// Year, and archives of up to a year, get a correct timeline.
+type yearInference struct {
+	reference time.Time
+	year      int
+	previous  time.Time
+}
+
+// newYearInference returns a yearInference for a log last written at
+// reference.
+func newYearInference(reference time.Time) *yearInference {
+	return &yearInference{reference: reference}
+}
+
+// infer sets the year of the timestamp of entry when it is yearless.
+func (inference *yearInference) infer(entry *logEntry) {
+	if !entry.yearless {
+		return
+	}
+	if inference.year == 0 {
+		inference.year = inference.reference.Year()
+		// Allow a day for clocks and time zones that differ.
+		if withYear(entry.timestamp, inference.year).After(inference.reference.Add(24 * time.Hour)) {
+			inference.year--
+		}
+	}
+	timestamp := withYear(entry.timestamp, inference.year)
+	if !inference.previous.IsZero() && timestamp.Before(inference.previous.Add(-yearJump)) {
+		inference.year++
+		timestamp = withYear(entry.timestamp, inference.year)
+	}
+	inference.previous = timestamp
+	entry.timestamp, entry.yearless = timestamp, false
+}
+
+// withYear returns timestamp in year.
+func withYear(timestamp time.Time, year int) time.Time {
+	return time.Date(year, timestamp.Month(), timestamp.Day(), timestamp.Hour(), timestamp.Minute(), timestamp.Second(), timestamp.Nanosecond(), timestamp.Location())
+}
+
+// modificationTime returns the modification time of the regular file
+// called filename, or the current time for other files, as the reference
+// of the yearInference of its lines.
+func modificationTime(filename string) time.Time {
+	if info, err := os.Stat(filename); err == nil && info.Mode().IsRegular() {
+		return info.ModTime()
+	}
+	return time.Now()
+}
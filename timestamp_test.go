@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestYearInference(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		lines     []string
+		want      []string
+	}{
+		{
+			name:      "same year",
+			reference: "2026-06-01T00:00:00Z",
+			lines:     []string{"Mar  3 10:00:00", "May 30 23:59:59"},
+			want:      []string{"2026-03-03T10:00:00Z", "2026-05-30T23:59:59Z"},
+		},
+		{
+			name:      "after the reference is the year before",
+			reference: "2026-01-05T00:00:00Z",
+			lines:     []string{"Dec 30 10:00:00", "Jan  2 08:00:00"},
+			want:      []string{"2025-12-30T10:00:00Z", "2026-01-02T08:00:00Z"},
+		},
+		{
+			name:      "a day of clock difference",
+			reference: "2026-06-01T00:00:00Z",
+			lines:     []string{"Jun  1 12:00:00"},
+			want:      []string{"2026-06-01T12:00:00Z"},
+		},
+		{
+			name:      "out of order lines keep their year",
+			reference: "2026-06-01T00:00:00Z",
+			lines:     []string{"May  2 10:00:00", "May  1 23:00:00", "May  2 10:00:01"},
+			want:      []string{"2026-05-02T10:00:00Z", "2026-05-01T23:00:00Z", "2026-05-02T10:00:01Z"},
+		},
+		{
+			name:      "leap day",
+			reference: "2024-06-01T00:00:00Z",
+			lines:     []string{"Feb 28 23:59:59", "Feb 29 00:00:00", "Mar  1 00:00:00"},
+			want:      []string{"2024-02-28T23:59:59Z", "2024-02-29T00:00:00Z", "2024-03-01T00:00:00Z"},
+		},
+	}
+
+	location := logLocation
+	logLocation = time.UTC
+	defer func() { logLocation = location }()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reference, err := time.Parse(time.RFC3339, test.reference)
+			if err != nil {
+				t.Fatal(err)
+			}
+			years := newYearInference(reference)
+			for i, line := range test.lines {
+				var entry logEntry
+				parseTimestamp([]byte(line), &entry)
+				if !entry.yearless {
+					t.Fatalf("%q: timestamp is not yearless", line)
+				}
+				years.infer(&entry)
+				if got := entry.timestamp.Format(time.RFC3339); got != test.want[i] {
+					t.Errorf("%q: got %s, want %s", line, got, test.want[i])
+				}
+			}
+		})
+	}
+}
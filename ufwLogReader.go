@@ -2,12 +2,16 @@
 package main
 
 import (
-	"bufio"
-	"fmt"
+	"flag"
+	"io"
 	"log"
 	"os"
-	"regexp"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"text/template"
+	"time"
 )
 
 /* Example of a UFW log file (split by identifier/value):
@@ -36,14 +40,14 @@ import (
 
    	IP: 127.0.0.2	Amount of requests: 2
 
-		Port Number	Amount
-		23		2
+		Port Number  Amount
+		23                2
 
 	IP: 127.0.0.1	Amount of requests: 10
 
-		Port Number	Amount
-		22		8
-		23		2
+		Port Number  Amount
+		22                8
+		23                2
 
 
 	Total amount of requests: 12
@@ -51,107 +55,1029 @@ import (
 */
 
 // ipPortMapStruct contains the amount of requests from the specified IP
-// address. The ports map contain the amount of requests for every port
-// from the specified IP address.
+// address. ports contains the amount of requests for every port from the
+// specified IP address, destinations the amount of requests for every
+// destination IP address and tcpFlags the amount of TCP packets for every
+// combination of flags, e.g. "SYN". Their keys are interned in keys, use
+// portCounts, destinationCounts and flagCounts to read them. The
+// activity map contains the amount of requests per minute, it is only
+// filled when sparklines are requested. When source addresses are
+// aggregated into subnets the hosts map contains the amount of requests of
+// every IP address in the subnet. firstSeen and lastSeen are the
+// timestamps of the first and last request. retransmissions is the amount
+// of repeated requests that were not counted because of -dedup. The tags
+// map contains the amount of requests for every tag.
 type ipPortMapStruct struct {
 	amountOfRequests int
-	ports            map[string]int
+	retransmissions  int
+	keys             *internTable
+	ports            countList
+	destinations     countList
+	tcpFlags         countList
+	activity         map[time.Time]int
+	hosts            map[string]int
+	tags             map[string]int
+	firstSeen        time.Time
+	lastSeen         time.Time
 }
 
 // ipPortMapMap holds a RWMutex to be goroutine save when multiple log files
-// are provided. ipPortMapMap contains pointers to ipPortMapStructs.
+// are provided. ipPortMapMap contains pointers to ipPortMapStructs,
+// hourlyRequests the amount of requests for every hour of the logs and
+// portHours the amount of requests per port for every hour of the day. When
+// bucketSize is set the requests are also counted per time bucket of that
+// size, per IP address if bucketsPerIP is set. trackActivity enables the
+// per minute activity of every IP address. When alerts is set every request
+// is passed to the alert engine. When aggregatePrefix4 or aggregatePrefix6
+// is set source addresses are counted per subnet of that prefix length.
+// Requests matching excluded are not counted at all. When portRanges is set
+// destination ports are counted per range. When maxIPs is set the IP
+// addresses with the least requests are merged into a single "other" entry
+// once there are more than maxIPs IP addresses. Requests of log lines with
+// a destination port but without a source IP address are counted in
+// unknownSource. When anonymizer is set source addresses are replaced by
+// their pseudonyms before they are counted. When dedupWindow is set
+// repeated requests within the window are counted as retransmissions.
+// Every counted request is also written to the sinks. The tags of
+// tagRules are attached to every request, when onlyTags is set only the
+// requests with one of those tags are counted. tagStats counts the
+// requests of every tag. When filter is set only the requests matching it
+// are counted. keys interns the ports, destinations and TCP flags counted
+// per IP address.
 type ipPortMapMap struct {
 	sync.RWMutex
-	ipPortMapMap map[string]*ipPortMapStruct
-}
+	ipPortMapMap   map[string]*ipPortMapStruct
+	keys           *internTable
+	hourlyRequests map[time.Time]int
+	portHours      map[string]*[24]int
+	unknownSource  *ipPortMapStruct
+	bucketSize     time.Duration
+	bucketsPerIP   bool
+	buckets        map[timeBucket]int
+	trackActivity  bool
+	alerts         *alertEngine
+
+	aggregatePrefix4 int
+	aggregatePrefix6 int
+	excluded         *exclusions
+	portRanges       []portRange
+	maxIPs           int
+	anonymizer       *anonymizer
 
-// Placeholder is a port was found but no IP address.
-const iPAdressNotFound = "unknown"
+	dedupWindow  time.Duration
+	dedupCounted map[dedupKey]time.Time
+	dedupLatest  time.Time
+	dedupPruned  int
+
+	sinks    []eventSink
+	tagRules []tagRule
+	onlyTags []string
+	tagStats map[string]*groupStats
+	filter   filterExpression
+}
 
 func main() {
+	commandName, args := splitCommand(os.Args[1:])
+	switch commandName {
+	case "selftest":
+		os.Exit(runSelftest(args))
+	case "rules":
+		os.Exit(runRules(args))
+	case "bench":
+		os.Exit(runBench(args))
+	case "diff":
+		os.Exit(runDiff(args))
+	case "gen":
+		os.Exit(runGen(args))
+	}
+
+	concurrency := flag.Int("concurrency", runtime.NumCPU(), "maximum `number` of files that are read at the same time")
+	verbose := flag.Bool("v", false, "verbose, also print per-file statistics and timings on stderr")
+	quiet := flag.Bool("q", false, "quiet, only print the report and errors")
+	showProgress := flag.Bool("progress", false, "show the progress of every file being read on stderr")
+	maxLineBytes := flag.Int("max-line-bytes", defaultMaxLineBytes, "maximum length of a log line in `bytes`, longer lines are skipped and reported")
+	lowMemory := flag.Bool("low-memory", false, "use less memory for small devices: read and parse one file at a time with short queues, keep at most 10000 IP addresses and collect garbage more often")
+	parsers := flag.Int("parsers", runtime.NumCPU(), "`number` of goroutines parsing lines")
+	readQueue := flag.Int("read-queue", 16, "`number` of chunks of read lines that can wait to be parsed")
+	parseQueue := flag.Int("parse-queue", 16, "`number` of chunks of parsed lines that can wait to be counted")
+	rejectsFile := flag.String("rejects", "", "write the lines that are not requests to `file` as file:line: text, for inspection")
+	filterText := flag.String("filter", "", "only count the requests matching this `expression`, e.g. 'dpt==22 && proto==\"TCP\" && src in 45.0.0.0/8'")
+	excludeFiles := flag.String("exclude-files", "", "comma separated glob `patterns` of files not to read from directory and glob arguments, e.g. \"*.gz\"")
+	rotated := flag.Bool("include-rotated", false, "also read the rotated files of every file, e.g. ufw.log.1 and ufw.log.2.gz for ufw.log, oldest first")
+	useMmap := flag.Bool("mmap", false, "memory map the files instead of reading them, faster on some systems")
+	inputFormat := flag.String("input-format", "auto", "`format` of the log lines: auto reads every line with a destination port, ufw only ufw lines, firewalld only firewalld lines, pf reads OpenBSD pflog lines printed by tcpdump and windows the Windows Firewall pfirewall.log, both with their own parser instead of -parser")
+	timeZone := flag.String("tz", "", "time `zone` of log timestamps without one, e.g. Europe/Amsterdam (default local)")
+	logPrefixes := flag.String("log-prefix", "", "only read the lines with one of these comma separated iptables LOG `prefixes`, e.g. \"DROPPED:\"")
+	suricataSIDs := flag.String("suricata-sids", "", "`file` keeping the signature IDs and revisions of -format suricata-rules across runs")
+	parserName := flag.String("parser", "fields", "log line `parser`, fields or the slower regexp")
+	configFile := flag.String("config", "", "JSON configuration `file` with alert rules")
+	alertExit := flag.Bool("alert-exit", false, "exit with status 3 when an alert rule fired")
+	format := flag.String("format", "text", "report `format`, text, markdown, csv, influx or suricata-rules")
+	columnList := flag.String("columns", "", "comma separated `columns` of a table with one row per IP address, e.g. ip,count,ports,first_seen, used by the text and csv formats")
+	markdownTop := flag.Int("markdown-top", 20, "number of rows in the Markdown tables, 0 for all")
+	templateFile := flag.String("template", "", "render the report through the Go text/template in `file` instead")
+	reportHTML := flag.String("report-html", "", "also write a self-contained HTML report to `file`")
+	human := flag.Bool("human", false, "print amounts like 1.2M and 3.4k in the text output, machine formats keep exact numbers")
+	servicesFile := flag.String("services", "/etc/services", "services `file` used to show the service names of ports")
+	severityList := flag.String("severity-thresholds", "100,1000", "amounts of `requests` at which an IP address is rated medium and high severity in the HTML report")
+	labelsFile := flag.String("labels", "", "CSV `file` mapping IP addresses or CIDRs to owner/team/asset labels")
+	dnsblZones := flag.String("dnsbl", "", "comma separated DNSBL `zones` to check the top offenders against, e.g. zen.spamhaus.org,bl.blocklist.de")
+	dnsblTop := flag.Int("dnsbl-top", 10, "number of top offenders to check against the DNSBLs")
+	dnsblTimeout := flag.Duration("dnsbl-timeout", 5*time.Second, "timeout of a single DNSBL query")
+	greyNoise := flag.Bool("greynoise", false, "classify the top offenders using the GreyNoise community API")
+	greyNoiseKey := flag.String("greynoise-key", "", "GreyNoise API `key`")
+	greyNoiseTop := flag.Int("greynoise-top", 10, "number of top offenders to classify using GreyNoise")
+	greyNoiseTimeout := flag.Duration("greynoise-timeout", 10*time.Second, "timeout of a single GreyNoise request")
+	whois := flag.Bool("whois", false, "look up the network name, country and abuse contact of the top offenders using RDAP")
+	whoisTop := flag.Int("whois-top", 10, "number of top offenders to look up using RDAP")
+	whoisServer := flag.String("whois-server", defaultRDAPServer, "RDAP server `URL`, the IP address is appended")
+	whoisTimeout := flag.Duration("whois-timeout", 10*time.Second, "timeout of a single RDAP request")
+	probe := flag.Bool("probe", false, "check whether the top offenders are still alive with a single TCP connect per port, this contacts them")
+	probeTop := flag.Int("probe-top", 10, "number of top offenders to probe")
+	probePorts := flag.String("probe-ports", "443,80,22", "comma separated `ports` probed in order until one answers")
+	probeTimeout := flag.Duration("probe-timeout", 2*time.Second, "timeout of a single probe connect")
+	probeInterval := flag.Duration("probe-interval", time.Second, "minimum `duration` between two probe connects")
+	pdnsSource := flag.String("pdns", "", "passive DNS server `URL` (the IP address is appended) or local file with records in Passive DNS Common Output Format")
+	pdnsTop := flag.Int("pdns-top", 10, "number of top offenders to look up in passive DNS")
+	pdnsRecent := flag.Duration("pdns-recent", 30*24*time.Hour, "only list domains seen resolving within this `duration`")
+	pdnsTimeout := flag.Duration("pdns-timeout", 10*time.Second, "timeout of a single passive DNS request")
+	enrichmentLimit := flag.Int("enrichment-budget", -1, "maximum `number` of network lookups of all enrichment providers together, -1 for no limit")
+	offline := flag.Bool("offline", false, "disable all network lookups, local enrichment data is still used")
+	noNetwork := flag.Bool("no-network", false, "guarantee no network connections are made, fail when a network feature is configured")
+	webhookURL := flag.String("webhook", "", "post a notification to this webhook `URL` for every IP address that crosses -webhook-threshold")
+	webhookFormat := flag.String("webhook-format", "slack", "webhook message `format`, slack or json")
+	elasticsearchURL := flag.String("elasticsearch", "", "ship the requests to the Elasticsearch server at `URL` using the bulk API, e.g. http://localhost:9200")
+	elasticsearchIndex := flag.String("elasticsearch-index", "ufw-%Y.%m", "Elasticsearch index `pattern`, %Y, %m and %d are replaced by the date of the request")
+	elasticsearchMode := flag.String("elasticsearch-mode", "events", "ship every request (events) or a document per IP address (aggregates) to Elasticsearch")
+	dumpFile := flag.String("dump", "", "also stream every request to `file`, gzip compressed when it ends in .gz")
+	dumpFormat := flag.String("dump-format", "csv", "`format` of the -dump file, csv or ndjson")
+	lokiURL := flag.String("loki-url", "", "push the requests to the Grafana Loki server at `URL`, e.g. http://localhost:3100")
+	lokiJob := flag.String("loki-job", "ufw", "value of the job label of the Loki streams")
+	metricsEndpoint := flag.String("metrics", "", "in daemon mode, emit request counters to a statsd or Graphite `endpoint`, statsd://host:8125 or graphite://host:2003")
+	metricsInterval := flag.Duration("metrics-interval", 10*time.Second, "`duration` between metric emits")
+	metricsPrefix := flag.String("metrics-prefix", "ufw", "`prefix` of the metric names")
+	webhookThreshold := flag.Int("webhook-threshold", 100, "amount of requests from one IP address that triggers a webhook notification")
+	daemon := flag.Bool("daemon", false, "keep following the log files and emit a summary every -interval")
+	interval := flag.Duration("interval", time.Hour, "`duration` between summaries in daemon mode")
+	daemonOutput := flag.String("daemon-output", "stdout", "`destination` of the summaries in daemon mode: stdout, syslog or a file name")
+	live := flag.Bool("live", false, "in daemon mode, show a refreshing top-style view of the request rates per IP address and port instead of the summaries on stdout")
+	liveWindow := flag.Duration("live-window", 5*time.Minute, "time `window` of the rates of the -live view")
+	liveTop := flag.Int("live-top", 10, "`number` of IP addresses and ports in the -live view")
+	staleAfter := flag.Duration("stale", 0, "in daemon mode, report a log file that receives no lines for this `duration`, e.g. 30m")
+	daemonReset := flag.Bool("daemon-reset", false, "start counting from zero after every summary in daemon mode")
+	histogram := flag.String("histogram", "", "show the amount of requests per `hour or day`")
+	sparklines := flag.Bool("sparklines", false, "show a sparkline of the activity of every IP address over the analyzed time window")
+	chart := flag.Bool("chart", false, "show bar charts of the top IP addresses and ports")
+	chartTop := flag.Int("chart-top", 10, "number of IP addresses and ports in the bar charts")
+	heatmap := flag.Bool("heatmap", false, "show the amount of requests per port and hour of the day")
+	heatmapTop := flag.Int("heatmap-top", 20, "number of ports in the heatmap")
+	heatmapCSV := flag.String("heatmap-csv", "", "also write the port × hour heatmap as CSV to `file`")
+	portRangeList := flag.String("port-ranges", "", "count destination ports per `range`: iana or a list like privileged=0-1023,high=1024-65535")
+	aggregatePrefix := flag.Int("aggregate-prefix", 0, "aggregate IPv4 source addresses into subnets of this prefix `length`, e.g. 24")
+	aggregatePrefix6 := flag.Int("aggregate-prefix6", 0, "aggregate IPv6 source addresses into subnets of this prefix `length`, e.g. 64")
+	anonymize := flag.String("anonymize", "", "anonymize source IP addresses for shared reports: `mask` keeps the /24 or /48, hash also appends a keyed hash of the address")
+	anonymizeKey := flag.String("anonymize-key", "", "`key` of the hash of -anonymize hash, random for every run when empty")
+	dedupWindow := flag.Duration("dedup", 0, "count repeated requests of the same source IP address, port and protocol within this `duration`, e.g. 2s, once and the repeats as retransmissions")
+	onlyTags := flag.String("tags", "", "only count the requests with one of these comma separated `tags` of the -config tag rules")
+	excludePorts := flag.String("exclude-ports", "", "comma separated destination `ports` that are not counted, e.g. 80,443")
+	excludeIPs := flag.String("exclude-ips", "", "comma separated source IP `addresses` or CIDRs that are not counted")
+	bucketSize := flag.Duration("buckets", 0, "emit the amount of requests per time bucket of this `duration`, e.g. 5m, instead of the report")
+	bucketsPerIP := flag.Bool("buckets-per-ip", false, "count the time buckets per IP address instead of globally")
+	bucketsFormat := flag.String("buckets-format", "csv", "output `format` of the time buckets, csv, json or influx")
+	maxIPs := flag.Int("max-ips", 0, "keep at most this `number` of IP addresses in memory, the ones with the least requests are merged into \"other\", 0 for no limit")
+	switch commandName {
+	case "help":
+		if len(args) == 0 {
+			printUsage(os.Stdout, flag.CommandLine)
+			os.Exit(exitSuccess)
+		}
+		if err := printCommandHelp(os.Stdout, args[0], flag.CommandLine); err != nil {
+			fatal(err)
+		}
+		os.Exit(exitSuccess)
+	case "completion":
+		if len(args) != 1 {
+			log.Fatal("Usage: ufwLogReader completion bash|zsh")
+		}
+		if err := printCompletion(os.Stdout, args[0], flag.CommandLine); err != nil {
+			fatal(err)
+		}
+		os.Exit(exitSuccess)
+	case "follow":
+		flag.Set("daemon", "true")
+	case "export":
+		flag.Set("format", "csv")
+	}
+
+	// The flag package exits with status 2 on errors, which is reserved
+	// for unreadable files.
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	flag.CommandLine.Usage = func() {
+		if commandName == "report" {
+			printUsage(os.Stderr, flag.CommandLine)
+		} else {
+			printCommandHelp(os.Stderr, commandName, flag.CommandLine)
+		}
+	}
+	if err := flag.CommandLine.Parse(args); err == flag.ErrHelp {
+		os.Exit(exitSuccess)
+	} else if err != nil {
+		os.Exit(exitUsage)
+	}
+
+	if *lowMemory {
+		if err := applyLowMemoryProfile(flag.CommandLine); err != nil {
+			fatal(err)
+		}
+	}
+	if *verbose && *quiet {
+		log.Fatal("-v and -q can't be combined")
+	}
+	if *verbose {
+		verbosity = verbosityVerbose
+	} else if *quiet {
+		verbosity = verbosityQuiet
+	}
+	if *format != "text" && *format != "markdown" && *format != "csv" && *format != "influx" && *format != "suricata-rules" {
+		log.Fatalf("unknown report format %q, use text, markdown, csv, influx or suricata-rules", *format)
+	}
+	var outputTemplate *template.Template
+	if *templateFile != "" {
+		var err error
+		outputTemplate, err = loadTemplate(*templateFile)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if *format == "influx" && *bucketSize > 0 {
+		*bucketsFormat = "influx"
+	}
+	severityThresholds, err := parseSeverityThresholds(*severityList)
+	if err != nil {
+		fatal(err)
+	}
+	var columns []string
+	if *columnList != "" || *format == "csv" {
+		if *columnList == "" {
+			enrichment := enrichmentColumns(*dnsblZones != "", *greyNoise, *whois, *probe, *pdnsSource != "")
+			*columnList = strings.Join(append([]string{defaultColumns}, enrichment...), ",")
+		}
+		var err error
+		columns, err = parseColumns(*columnList)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if *histogram != "" && *histogram != "hour" && *histogram != "day" {
+		log.Fatalf("unknown histogram resolution %q, use hour or day", *histogram)
+	}
+	if *maxLineBytes < 1 {
+		log.Fatalf("invalid maximum line length %d", *maxLineBytes)
+	}
+	if err := checkQueueSize("read-queue", *readQueue); err != nil {
+		fatal(err)
+	}
+	if err := checkQueueSize("parse-queue", *parseQueue); err != nil {
+		fatal(err)
+	}
+	if *bucketsFormat != "csv" && *bucketsFormat != "json" && *bucketsFormat != "influx" {
+		log.Fatalf("unknown bucket format %q, use csv, json or influx", *bucketsFormat)
+	}
+	if *bucketSize < 0 {
+		log.Fatalf("invalid bucket duration %s, it must be positive", *bucketSize)
+	}
+
+	var configuration *config
+	if *configFile != "" {
+		var err error
+		configuration, err = loadConfig(*configFile)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	if *noNetwork {
+		var configured []string
+		if *dnsblZones != "" {
+			configured = append(configured, "-dnsbl")
+		}
+		if *greyNoise {
+			configured = append(configured, "-greynoise")
+		}
+		if *whois {
+			configured = append(configured, "-whois")
+		}
+		if *probe {
+			configured = append(configured, "-probe")
+		}
+		if strings.HasPrefix(*pdnsSource, "http://") || strings.HasPrefix(*pdnsSource, "https://") {
+			configured = append(configured, "-pdns")
+		}
+		if *webhookURL != "" {
+			configured = append(configured, "-webhook")
+		}
+		if *elasticsearchURL != "" {
+			configured = append(configured, "-elasticsearch")
+		}
+		if *lokiURL != "" {
+			configured = append(configured, "-loki-url")
+		}
+		if *metricsEndpoint != "" {
+			configured = append(configured, "-metrics")
+		}
+		if configuration != nil {
+			for _, rule := range configuration.Alerts {
+				if rule.Action == "webhook" {
+					configured = append(configured, "alert rule "+rule.Name)
+				}
+			}
+		}
+		if len(configured) > 0 {
+			log.Fatalf("-no-network can't be combined with network features: %s", strings.Join(configured, ", "))
+		}
+		disableNetwork()
+	}
+
+	var notifier *webhookNotifier
+	if *webhookURL != "" {
+		var err error
+		notifier, err = newWebhookNotifier(*webhookURL, *webhookFormat, *webhookThreshold)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	var labels *labelTable
+	if *labelsFile != "" {
+		var err error
+		labels, err = loadLabelTable(*labelsFile)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	if *aggregatePrefix < 0 || *aggregatePrefix > 32 {
+		log.Fatalf("invalid -aggregate-prefix %d, use 1 to 32 or 0 to count every address", *aggregatePrefix)
+	}
+	if *aggregatePrefix6 < 0 || *aggregatePrefix6 > 128 {
+		log.Fatalf("invalid -aggregate-prefix6 %d, use 1 to 128 or 0 to count every address", *aggregatePrefix6)
+	}
+
 	ipPortMapMap := newIPPortMapMap()
-	files := os.Args[1:]
-	var waitGroup sync.WaitGroup
+	ipPortMapMap.bucketSize = *bucketSize
+	ipPortMapMap.bucketsPerIP = *bucketsPerIP
+	ipPortMapMap.trackActivity = *sparklines
+	ipPortMapMap.aggregatePrefix4 = *aggregatePrefix
+	ipPortMapMap.aggregatePrefix6 = *aggregatePrefix6
+	ipPortMapMap.maxIPs = *maxIPs
+	ipPortMapMap.dedupWindow = *dedupWindow
+	if *anonymize != "" {
+		if *dnsblZones != "" || *greyNoise || *whois || *probe || *pdnsSource != "" {
+			log.Fatal("-anonymize can't be combined with -dnsbl, -greynoise, -whois, -probe or -pdns, they need the IP addresses")
+		}
+		if *aggregatePrefix > anonymizePrefix4 || *aggregatePrefix6 > anonymizePrefix6 {
+			log.Fatalf("-anonymize can't be combined with aggregate prefixes longer than /%d or /%d", anonymizePrefix4, anonymizePrefix6)
+		}
+		ipPortMapMap.anonymizer, err = newAnonymizer(*anonymize, *anonymizeKey)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	excluded, err := parseExclusions(*excludePorts, *excludeIPs)
+	if err != nil {
+		fatal(err)
+	}
+	ipPortMapMap.excluded = excluded
+	if *portRangeList != "" {
+		ipPortMapMap.portRanges, err = parsePortRanges(*portRangeList)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if configuration != nil && len(configuration.Tags) > 0 {
+		ipPortMapMap.tagRules = configuration.Tags
+	}
+	if *filterText != "" {
+		ipPortMapMap.filter, err = parseFilter(*filterText)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if *onlyTags != "" {
+		if ipPortMapMap.tagRules == nil {
+			log.Fatal("-tags needs tag rules in the -config file")
+		}
+		ipPortMapMap.onlyTags = splitList(*onlyTags)
+	}
+	if configuration != nil && len(configuration.Alerts) > 0 {
+		ipPortMapMap.alerts = newAlertEngine(configuration.Alerts, false)
+	}
+	var elasticsearch *elasticsearchSink
+	if *elasticsearchURL != "" {
+		if (*daemon || *bucketSize > 0) && *elasticsearchMode == "aggregates" {
+			log.Fatal("-elasticsearch-mode aggregates can't be combined with -daemon or -buckets")
+		}
+		elasticsearch, err = newElasticsearchSink(*elasticsearchURL, *elasticsearchIndex, *elasticsearchMode)
+		if err != nil {
+			fatal(err)
+		}
+		elasticsearch.redaction = configuration.sinkRedaction("elasticsearch")
+		ipPortMapMap.sinks = append(ipPortMapMap.sinks, configuration.routedSink("elasticsearch", elasticsearch))
+	}
+	if *lokiURL != "" {
+		loki := newLokiSink(*lokiURL, *lokiJob)
+		loki.redaction = configuration.sinkRedaction("loki")
+		ipPortMapMap.sinks = append(ipPortMapMap.sinks, configuration.routedSink("loki", loki))
+	}
+	var liveTopView *liveView
+	if *live {
+		if !*daemon {
+			log.Fatal("-live needs -daemon or the follow command")
+		}
+		liveTopView = newLiveView(*liveWindow, *liveTop)
+		ipPortMapMap.sinks = append(ipPortMapMap.sinks, liveTopView)
+	}
+	if *staleAfter > 0 && !*daemon {
+		log.Fatal("-stale needs -daemon or the follow command")
+	}
+	var metrics *metricsSink
+	if *metricsEndpoint != "" {
+		if !*daemon {
+			log.Fatal("-metrics needs -daemon or the follow command")
+		}
+		if *metricsInterval <= 0 {
+			log.Fatal("-metrics-interval must be positive")
+		}
+		metrics, err = newMetricsSink(*metricsEndpoint, *metricsPrefix, *metricsInterval)
+		if err != nil {
+			fatal(err)
+		}
+		metrics.redaction = configuration.sinkRedaction("metrics")
+		ipPortMapMap.sinks = append(ipPortMapMap.sinks, configuration.routedSink("metrics", metrics))
+	}
+	var dump *dumpSink
+	if *dumpFile != "" {
+		dump, err = newDumpSink(*dumpFile, *dumpFormat)
+		if err != nil {
+			fatal(err)
+		}
+		dump.redaction = configuration.sinkRedaction("dump")
+		ipPortMapMap.sinks = append(ipPortMapMap.sinks, configuration.routedSink("dump", dump))
+	}
+	if *format == "influx" && *bucketSize == 0 {
+		influx := newInfluxSink(os.Stdout)
+		influx.redaction = configuration.sinkRedaction("influx")
+		ipPortMapMap.sinks = append(ipPortMapMap.sinks, configuration.routedSink("influx", influx))
+	}
+	parse, ok := newLineParser(*parserName)
+	if !ok {
+		log.Fatalf("unknown parser %q, use fields or regexp", *parserName)
+	}
+	if (*inputFormat == "pf" || *inputFormat == "windows") && *parserName != "fields" {
+		log.Fatalf("-parser %s can't be combined with -input-format %s, its lines have their own parser", *parserName, *inputFormat)
+	}
+	parse, err = inputFormatParser(parse, *inputFormat)
+	if err != nil {
+		fatal(err)
+	}
+	if *logPrefixes != "" {
+		parse = logPrefixParser(parse, splitList(*logPrefixes))
+	}
+	if *timeZone != "" {
+		logLocation, err = time.LoadLocation(*timeZone)
+		if err != nil {
+			log.Fatalf("-tz: %v", err)
+		}
+	}
+	files, err := expandArguments(flag.Args(), splitList(*excludeFiles))
+	if err != nil {
+		fatal(err)
+	}
+	if *rotated {
+		if *daemon {
+			log.Fatal("-include-rotated can't be combined with -daemon, only the current files are followed")
+		}
+		files, err = includeRotated(files)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	if *daemon {
+		if len(files) == 0 {
+			log.Fatal("Daemon mode needs at least one file argument.")
+		}
+		if *interval <= 0 {
+			log.Fatal("-interval must be positive")
+		}
+		fatal(runDaemon(files, ipPortMapMap, parse, daemonOptions{
+			interval: *interval,
+			output:   *daemonOutput,
+			reset:    *daemonReset,
+			labels:   labels,
+			services: loadServices(*servicesFile),
+			notifier: notifier,
+			metrics:  metrics,
+			stale:    *staleAfter,
+			live:     liveTopView,
+
+			maxLineBytes: *maxLineBytes,
+		}))
+	}
 
+	var failures []scanFailure
 	if len(files) > 0 {
-		for _, filename := range files {
-			file, err := os.Open(filename)
+		var scanProgress *progress
+		if *showProgress {
+			scanProgress = newProgress(os.Stderr, time.Second)
+		}
+		var rejects *rejectsWriter
+		if *rejectsFile != "" {
+			rejects, err = newRejectsWriter(*rejectsFile)
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
+			}
+		}
+		started := time.Now()
+		failures = scanFiles(files, ipPortMapMap, scanOptions{
+			parse:        parse,
+			concurrency:  *concurrency,
+			parsers:      *parsers,
+			readQueue:    *readQueue,
+			parseQueue:   *parseQueue,
+			progress:     scanProgress,
+			maxLineBytes: *maxLineBytes,
+			mmap:         *useMmap,
+			rejects:      rejects,
+		})
+		if rejects != nil {
+			if err := rejects.close(); err != nil {
+				log.Println(err)
 			}
-
-			ipPattern := regexp.MustCompile(`SRC=(\d{1,3}.\d{1,3}.\d{1,3}.\d{1,3})`)
-			portPattern := regexp.MustCompile(`DPT=(\d{1,5})`)
-			waitGroup.Add(1)
-			go scanFile(file, ipPortMapMap, ipPattern, portPattern, &waitGroup)
 		}
+		if scanProgress != nil {
+			scanProgress.stop()
+		}
+		verbosef("Read %d files in %s\n", len(files), time.Since(started).Round(time.Millisecond))
 	} else {
-		fmt.Println("No file arguments were given.")
+		infof("No file arguments were given.\n")
 	}
 
-	waitGroup.Wait()
+	for _, err := range flushSinks(ipPortMapMap.sinks) {
+		log.Println(err)
+	}
+	if dump != nil {
+		if err := dump.close(); err != nil {
+			log.Println(err)
+		}
+	}
 
-	totalRequests := 0
-	mostRequestedPort := make(map[string]int)
+	// Report the failures after the report, where they are noticed.
+	defer func() {
+		if len(failures) > 0 {
+			printScanFailures(os.Stderr, failures, len(files))
+		}
+		if status := exitStatus(failures, ipPortMapMap.alerts, *alertExit); status != exitSuccess {
+			os.Exit(status)
+		}
+	}()
 
-	for ipAddress := range ipPortMapMap.ipPortMapMap {
-		if ipPortMapMap.ipPortMapMap[ipAddress].amountOfRequests > 1 {
-			fmt.Printf("IP: %s\tAmount of requests: %d\n\n", ipAddress, ipPortMapMap.ipPortMapMap[ipAddress].amountOfRequests)
-			fmt.Printf("\tPort Number\tAmount\n")
+	// The points of the requests are written while reading.
+	if *format == "influx" && *bucketSize == 0 {
+		return
+	}
 
-			for portNumber, amount := range ipPortMapMap.ipPortMapMap[ipAddress].ports {
-				fmt.Printf("\t%s\t\t%d\n", portNumber, amount)
-				mostRequestedPort[portNumber] += amount
+	if *bucketSize > 0 {
+		if err := writeBuckets(os.Stdout, ipPortMapMap.buckets, *bucketsPerIP, *bucketsFormat); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if notifier != nil {
+		for _, err := range notifier.notify(notifier.crossedThresholds(ipPortMapMap, labels)) {
+			log.Println(err)
+		}
+	}
+
+	budget := newEnrichmentBudget(*enrichmentLimit, *offline)
+
+	var dnsblListings map[string][]string
+	if *dnsblZones != "" {
+		dnsblListings = lookupDNSBLs(ipPortMapMap.topIPAddresses(*dnsblTop), strings.Split(*dnsblZones, ","), budget, *dnsblTimeout)
+	}
+
+	ipAddresses := ipPortMapMap.topIPAddresses(0)
+	var greyNoiseClassifications map[string]string
+	if *greyNoise {
+		greyNoiseClassifications = lookupGreyNoise(ipPortMapMap.topIPAddresses(*greyNoiseTop), *greyNoiseKey, budget, *greyNoiseTimeout)
+		ipAddresses = downRankBenign(ipAddresses, greyNoiseClassifications)
+	}
+
+	var rdapNetworks map[string]rdapNetwork
+	if *whois {
+		rdapNetworks = lookupRDAP(ipPortMapMap.topIPAddresses(*whoisTop), *whoisServer, budget, *whoisTimeout)
+	}
+
+	var probeResults map[string]string
+	if *probe {
+		probeResults = probeHosts(ipPortMapMap.topIPAddresses(*probeTop), splitList(*probePorts), budget, *probeTimeout, *probeInterval)
+	}
+
+	var pdnsDomains map[string][]string
+	if *pdnsSource != "" {
+		var err error
+		pdnsDomains, err = lookupPassiveDNS(ipPortMapMap.topIPAddresses(*pdnsTop), *pdnsSource, time.Now().Add(-*pdnsRecent), budget, *pdnsTimeout)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if skipped := budget.skippedLookups(); skipped > 0 {
+		infof("Skipped %d enrichment lookups (offline or budget exhausted).\n", skipped)
+	}
+
+	firstActivity, lastActivity := ipPortMapMap.activityWindow()
+	report := &report{
+		ipPortMapMap:  ipPortMapMap,
+		ipAddresses:   ipAddresses,
+		labels:        labels,
+		services:      loadServices(*servicesFile),
+		dnsblListings: dnsblListings,
+		greyNoise:     greyNoiseClassifications,
+		pdnsDomains:   pdnsDomains,
+		rdapNetworks:  rdapNetworks,
+		probeResults:  probeResults,
+		sparklines:    *sparklines,
+		human:         *human,
+		firstActivity: firstActivity,
+		lastActivity:  lastActivity,
+
+		severityThresholds: severityThresholds,
+	}
+
+	if elasticsearch != nil {
+		elasticsearch.writeAggregates(report)
+		if err := elasticsearch.flush(); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if *heatmapCSV != "" {
+		file, err := os.Create(*heatmapCSV)
+		if err != nil {
+			fatal(err)
+		}
+		if err := writeHeatmapCSV(file, ipPortMapMap.portHours); err != nil {
+			fatal(err)
+		}
+		if err := file.Close(); err != nil {
+			fatal(err)
+		}
+	}
+	if *reportHTML != "" {
+		file, err := os.Create(*reportHTML)
+		if err != nil {
+			fatal(err)
+		}
+		if err := report.writeHTML(file); err != nil {
+			fatal(err)
+		}
+		if err := file.Close(); err != nil {
+			fatal(err)
+		}
+	}
+
+	switch {
+	case outputTemplate != nil:
+		if err := report.writeTemplate(os.Stdout, outputTemplate); err != nil {
+			fatal(err)
+		}
+		return
+	case *format == "markdown":
+		report.printMarkdown(os.Stdout, *markdownTop)
+		return
+	case *format == "suricata-rules":
+		sids, err := loadSuricataSIDs(*suricataSIDs)
+		if err != nil {
+			fatal(err)
+		}
+		if err := report.writeSuricataRules(os.Stdout, sids); err != nil {
+			fatal(err)
+		}
+		if *suricataSIDs != "" {
+			if err := saveSuricataSIDs(*suricataSIDs, sids); err != nil {
+				fatal(err)
 			}
+		}
+		return
+	case *format == "csv":
+		if err := report.writeCSV(os.Stdout, columns); err != nil {
+			fatal(err)
+		}
+		return
+	case columns != nil:
+		report.printColumns(os.Stdout, columns)
+	default:
+		report.printText(os.Stdout)
+	}
 
-			totalRequests += ipPortMapMap.ipPortMapMap[ipAddress].amountOfRequests
+	if *chart {
+		ipRequests := make(map[string]int)
+		for ipAddress, ipPortMapStruct := range ipPortMapMap.ipPortMapMap {
+			ipRequests[ipAddress] = ipPortMapStruct.amountOfRequests
+		}
+		_, portRequests, _ := report.totals()
+		printBarChart("Top IP addresses", ipRequests, *chartTop, *human)
+		portServiceRequests := make(map[string]int)
+		for portNumber, amount := range portRequests {
+			portServiceRequests[report.services.withService(portNumber)] = amount
+		}
+		printBarChart("Top ports", portServiceRequests, *chartTop, *human)
+	}
+
+	if *heatmap {
+		printHeatmap(os.Stdout, ipPortMapMap.portHours, *heatmapTop, *human)
+	}
+
+	if *histogram != "" {
+		if err := printHistogram(os.Stdout, ipPortMapMap.hourlyRequests, *histogram, *human); err != nil {
+			fatal(err)
 		}
 	}
-	fmt.Printf("\n\nTotal amount of requests: %d\n", totalRequests)
-	fmt.Printf("Most requestsed port: %s\n", getMostRequestedPort(mostRequestedPort))
 
 }
 
-// scanFile scans a file for IP addresses and port numbers.
-func scanFile(file *os.File, ipPortMapMap *ipPortMapMap, ipPattern *regexp.Regexp, portPattern *regexp.Regexp, wg *sync.WaitGroup) {
-	scanner := bufio.NewScanner(file)
-	defer wg.Done()
-	for scanner.Scan() {
-		ipAddress := ipPattern.FindStringSubmatch(scanner.Text())
-		portNumber := portPattern.FindStringSubmatch(scanner.Text())
-		if ipAddress != nil && portNumber != nil {
-			ipAddressString := ipAddress[1]
-			portNumberString := portNumber[1]
+// scanStats contains the amount of lines read from a file, the amount of
+// them that were requests and the amount of lines that were skipped because
+// they are longer than the maximum line length.
+type scanStats struct {
+	lines     int
+	requests  int
+	oversized int
+}
 
-			ipPortMapMap.Lock()
-			if ipPortMapMap.ipPortMapMap[ipAddressString] != nil {
-				ipPortMapMap.ipPortMapMap[ipAddressString].amountOfRequests++
-				ipPortMapMap.ipPortMapMap[ipAddressString].ports[portNumberString]++
-			} else {
-				ipPortMapMap.ipPortMapMap[ipAddressString] = newIPPortMapStruct()
-			}
+// scanFile scans a file for IP addresses, destination addresses, port
+// numbers and TCP flags. It returns the statistics of the file and the
+// error that stopped reading it, if any.
+func scanFile(file *logFile, ipPortMapMap *ipPortMapMap, parse lineParser, maxLineBytes int) (scanStats, error) {
+	lineReader := newLineReader(file, maxLineBytes)
+	var stats scanStats
+	years := newYearInference(file.yearReference())
+	for {
+		line, err := lineReader.readLine()
+		stats.oversized = lineReader.oversized
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, err
+		}
+		stats.lines++
+		if scanLine(line, ipPortMapMap, parse, years) {
+			stats.requests++
+		}
+	}
+}
+
+// scanLine adds the request of a single log line to ipPortMapMap, with the
+// year of its timestamp inferred by years. It reports whether the line is
+// a request logged by ufw, excluded requests included.
+func scanLine(line []byte, ipPortMapMap *ipPortMapMap, parse lineParser, years *yearInference) bool {
+	var entry logEntry
+	if request, _ := parseSafely(parse, line, &entry); !request {
+		return false
+	}
+	years.infer(&entry)
+	ipPortMapMap.countEntry(&entry)
+	return true
+}
+
+// countEntry adds the request of a parsed log line to ipPortMapMap.
+func (ipPortMapMap *ipPortMapMap) countEntry(entry *logEntry) {
+	if ipPortMapMap.tagRules != nil {
+		entry.tags = tagEntry(ipPortMapMap.tagRules, entry)
+	}
+	if ipPortMapMap.onlyTags != nil && !hasTag(entry.tags, ipPortMapMap.onlyTags) {
+		return
+	}
+	if ipPortMapMap.filter != nil && !ipPortMapMap.filter(entry) {
+		return
+	}
+
+	if entry.source != "" {
+		if ipPortMapMap.excluded.excludes(entry.source, entry.port) {
+			return
+		}
+		portKey := entry.port
+		if ipPortMapMap.portRanges != nil {
+			portKey = portRangeOf(entry.port, ipPortMapMap.portRanges)
+		}
+		host := entry.source
+		if ipPortMapMap.anonymizer != nil {
+			host = ipPortMapMap.anonymizer.anonymize(entry.source)
+		}
+		sourceString := host
+		if ipPortMapMap.aggregatePrefix4 > 0 || ipPortMapMap.aggregatePrefix6 > 0 {
+			sourceString = subnetOf(entry.source, ipPortMapMap.aggregatePrefix4, ipPortMapMap.aggregatePrefix6)
+		}
+
+		ipPortMapMap.Lock()
+		if ipPortMapMap.ipPortMapMap[sourceString] == nil {
+			ipPortMapMap.evictLowest()
+			ipPortMapMap.ipPortMapMap[sourceString] = newIPPortMapStruct(ipPortMapMap.keys)
+		}
+		if ipPortMapMap.isRetransmission(entry) {
+			ipPortMapMap.ipPortMapMap[sourceString].retransmissions++
 			ipPortMapMap.Unlock()
-		} else if portNumber != nil {
-			portNumberString := portNumber[1]
-			ipPortMapMap.ipPortMapMap[iPAdressNotFound].ports[portNumberString]++
-		} else {
-			continue
+			return
+		}
+		if sourceString != host {
+			addCount(&ipPortMapMap.ipPortMapMap[sourceString].hosts, host, 1)
 		}
+		ipPortMapMap.ipPortMapMap[sourceString].amountOfRequests++
+		if entry.hasTimestamp {
+			ipPortMapMap.ipPortMapMap[sourceString].seen(entry.timestamp)
+			ipPortMapMap.hourlyRequests[entry.timestamp.Truncate(time.Hour)]++
+			if ipPortMapMap.portHours[portKey] == nil {
+				ipPortMapMap.portHours[portKey] = new([24]int)
+			}
+			ipPortMapMap.portHours[portKey][entry.timestamp.Hour()]++
+			ipPortMapMap.countBucket(entry.timestamp, sourceString)
+			if ipPortMapMap.trackActivity {
+				addCount(&ipPortMapMap.ipPortMapMap[sourceString].activity, entry.timestamp.Truncate(time.Minute), 1)
+			}
+		}
+		ipPortMapMap.ipPortMapMap[sourceString].ports.add(ipPortMapMap.keys.id(portKey), 1)
+		if entry.destination != "" {
+			ipPortMapMap.ipPortMapMap[sourceString].destinations.add(ipPortMapMap.keys.id(entry.destination), 1)
+		}
+		if entry.flags != "" {
+			ipPortMapMap.ipPortMapMap[sourceString].tcpFlags.add(ipPortMapMap.keys.id(entry.flags), 1)
+		}
+		for _, tag := range entry.tags {
+			addCount(&ipPortMapMap.ipPortMapMap[sourceString].tags, tag, 1)
+			ipPortMapMap.countTag(tag, host, entry)
+		}
+		ipPortMapMap.Unlock()
+
+		if ipPortMapMap.alerts != nil {
+			ipPortMapMap.alerts.observe(entry.timestamp, entry.source, entry.port, entry.protocol)
+		}
+		ipPortMapMap.writeSinks(entry, host)
+	} else {
+		if ipPortMapMap.excluded.excludes("", entry.port) {
+			return
+		}
+		portKey := entry.port
+		if ipPortMapMap.portRanges != nil {
+			portKey = portRangeOf(entry.port, ipPortMapMap.portRanges)
+		}
+
+		ipPortMapMap.Lock()
+		ipPortMapMap.unknownSource.amountOfRequests++
+		if entry.hasTimestamp {
+			ipPortMapMap.unknownSource.seen(entry.timestamp)
+		}
+		ipPortMapMap.unknownSource.ports.add(ipPortMapMap.keys.id(portKey), 1)
+		if entry.destination != "" {
+			ipPortMapMap.unknownSource.destinations.add(ipPortMapMap.keys.id(entry.destination), 1)
+		}
+		if entry.flags != "" {
+			ipPortMapMap.unknownSource.tcpFlags.add(ipPortMapMap.keys.id(entry.flags), 1)
+		}
+		for _, tag := range entry.tags {
+			addCount(&ipPortMapMap.unknownSource.tags, tag, 1)
+			ipPortMapMap.countTag(tag, "", entry)
+		}
+		ipPortMapMap.Unlock()
+		ipPortMapMap.writeSinks(entry, "")
+	}
+}
+
+// countTag counts entry from source in the statistics of tag. The caller
+// must hold the lock.
+func (ipPortMapMap *ipPortMapMap) countTag(tag string, source string, entry *logEntry) {
+	if ipPortMapMap.tagStats[tag] == nil {
+		ipPortMapMap.tagStats[tag] = newGroupStats()
+	}
+	ipPortMapMap.tagStats[tag].count(source, entry.timestamp, entry.hasTimestamp)
+}
+
+// writeSinks writes entry to the sinks, with source as its source.
+func (ipPortMapMap *ipPortMapMap) writeSinks(entry *logEntry, source string) {
+	if len(ipPortMapMap.sinks) == 0 {
+		return
+	}
+	event := &sinkEvent{
+		timestamp:   entry.timestamp,
+		action:      entry.action,
+		inInterface: entry.inInterface,
+		source:      source,
+		destination: entry.destination,
+		protocol:    entry.protocol,
+		port:        entry.port,
+		flags:       entry.flags,
+		tags:        entry.tags,
+	}
+	for _, sink := range ipPortMapMap.sinks {
+		sink.writeEvent(event)
 	}
 }
 
-// newIPPortMapMap initializes the ipPortMapMap in the ipPortMapMap struct.
+// newIPPortMapMap initializes the maps in the ipPortMapMap struct.
 func newIPPortMapMap() *ipPortMapMap {
 	ipPortMapMap := new(ipPortMapMap)
-	ipPortMapMap.ipPortMapMap = make(map[string]*ipPortMapStruct)
+	ipPortMapMap.initMaps()
 	return ipPortMapMap
 }
 
-// newIPPortMapStruct initializes the ports map in the ipPortMapStruct.
-func newIPPortMapStruct() *ipPortMapStruct {
-	ipPortMapStruct := new(ipPortMapStruct)
-	ipPortMapStruct.ports = make(map[string]int)
-	return ipPortMapStruct
+// initMaps (re)initializes the maps in the ipPortMapMap struct.
+func (ipPortMapMap *ipPortMapMap) initMaps() {
+	ipPortMapMap.ipPortMapMap = make(map[string]*ipPortMapStruct)
+	ipPortMapMap.keys = newInternTable()
+	ipPortMapMap.hourlyRequests = make(map[time.Time]int)
+	ipPortMapMap.portHours = make(map[string]*[24]int)
+	ipPortMapMap.unknownSource = newIPPortMapStruct(ipPortMapMap.keys)
+	ipPortMapMap.dedupCounted = make(map[dedupKey]time.Time)
+	ipPortMapMap.dedupPruned = 0
+	ipPortMapMap.buckets = make(map[timeBucket]int)
+	ipPortMapMap.tagStats = make(map[string]*groupStats)
+}
+
+// reset forgets all requests counted so far.
+func (ipPortMapMap *ipPortMapMap) reset() {
+	ipPortMapMap.Lock()
+	defer ipPortMapMap.Unlock()
+	ipPortMapMap.initMaps()
+}
+
+// countBucket counts a request in the time bucket containing timestamp. It
+// does nothing when no bucket size is set.
+func (ipPortMapMap *ipPortMapMap) countBucket(timestamp time.Time, ipAddress string) {
+	if ipPortMapMap.bucketSize <= 0 {
+		return
+	}
+	bucket := timeBucket{start: timestamp.Truncate(ipPortMapMap.bucketSize)}
+	if ipPortMapMap.bucketsPerIP {
+		bucket.ipAddress = ipAddress
+	}
+	ipPortMapMap.buckets[bucket]++
+}
+
+// topIPAddresses returns the IP addresses sorted by their amount of
+// requests, highest first. If limit is larger than zero at most limit IP
+// addresses are returned.
+func (ipPortMapMap *ipPortMapMap) topIPAddresses(limit int) []string {
+	ipAddresses := make([]string, 0, len(ipPortMapMap.ipPortMapMap))
+	for ipAddress := range ipPortMapMap.ipPortMapMap {
+		ipAddresses = append(ipAddresses, ipAddress)
+	}
+	sort.Slice(ipAddresses, func(i, j int) bool {
+		a := ipPortMapMap.ipPortMapMap[ipAddresses[i]].amountOfRequests
+		b := ipPortMapMap.ipPortMapMap[ipAddresses[j]].amountOfRequests
+		if a != b {
+			return a > b
+		}
+		return ipAddresses[i] < ipAddresses[j]
+	})
+	if limit > 0 && len(ipAddresses) > limit {
+		ipAddresses = ipAddresses[:limit]
+	}
+	return ipAddresses
+}
+
+// seen extends the first and last seen timestamps with timestamp.
+func (ipPortMapStruct *ipPortMapStruct) seen(timestamp time.Time) {
+	if ipPortMapStruct.firstSeen.IsZero() || timestamp.Before(ipPortMapStruct.firstSeen) {
+		ipPortMapStruct.firstSeen = timestamp
+	}
+	if timestamp.After(ipPortMapStruct.lastSeen) {
+		ipPortMapStruct.lastSeen = timestamp
+	}
+}
+
+// newIPPortMapStruct returns an empty ipPortMapStruct, its ports,
+// destinations and TCP flags are interned in keys. The activity, hosts and
+// tags maps are made on first use, most IP addresses don't need them.
+func newIPPortMapStruct(keys *internTable) *ipPortMapStruct {
+	return &ipPortMapStruct{keys: keys}
+}
+
+// portCounts returns the amount of requests for every port.
+func (ipPortMapStruct *ipPortMapStruct) portCounts() map[string]int {
+	return ipPortMapStruct.ports.amounts(ipPortMapStruct.keys)
+}
+
+// destinationCounts returns the amount of requests for every destination
+// IP address.
+func (ipPortMapStruct *ipPortMapStruct) destinationCounts() map[string]int {
+	return ipPortMapStruct.destinations.amounts(ipPortMapStruct.keys)
+}
+
+// flagCounts returns the amount of TCP packets for every combination of
+// flags.
+func (ipPortMapStruct *ipPortMapStruct) flagCounts() map[string]int {
+	return ipPortMapStruct.tcpFlags.amounts(ipPortMapStruct.keys)
 }
 
 // getMostRequestedPort loops through the mostRequestedPortMap to find to
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Levels of the diagnostic messages written to stderr.
+const (
+	verbosityQuiet = iota - 1
+	verbosityNormal
+	verbosityVerbose
+)
+
+// verbosity is the level of the diagnostic messages that are written, set
+// by the -q and -v flags. Errors are always written.
+var verbosity = verbosityNormal
+
+// infof writes a diagnostic message to stderr unless quiet.
+func infof(format string, a ...interface{}) {
+	if verbosity >= verbosityNormal {
+		fmt.Fprintf(os.Stderr, format, a...)
+	}
+}
+
+// verbosef writes a diagnostic message to stderr when verbose.
+func verbosef(format string, a ...interface{}) {
+	if verbosity >= verbosityVerbose {
+		fmt.Fprintf(os.Stderr, format, a...)
+	}
+}
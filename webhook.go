@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webhookNotifier posts a message to a webhook when an IP address crosses
+// the hit threshold. Every IP address is notified at most once, or once
+// per period when the counters are reset.
+type webhookNotifier struct {
+	sync.Mutex
+	url       string
+	format    string
+	threshold int
+	client    *http.Client
+	notified  map[string]bool
+}
+
+// webhookMessage is the body posted to a generic JSON webhook.
+type webhookMessage struct {
+	IPAddress string         `json:"ip"`
+	Label     string         `json:"label,omitempty"`
+	Requests  int            `json:"requests"`
+	Threshold int            `json:"threshold"`
+	Ports     map[string]int `json:"ports"`
+}
+
+// newWebhookNotifier returns a notifier posting to url in the slack or json
+// format.
+func newWebhookNotifier(url string, format string, threshold int) (*webhookNotifier, error) {
+	if format != "slack" && format != "json" {
+		return nil, fmt.Errorf("unknown webhook format %q, use slack or json", format)
+	}
+	return &webhookNotifier{
+		url:       url,
+		format:    format,
+		threshold: threshold,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		notified:  make(map[string]bool),
+	}, nil
+}
+
+// crossedThresholds returns a message for every IP address that reached
+// the threshold and was not notified before, highest amount of requests
+// first, and marks them as notified. The caller must hold the read lock of
+// ipPortMapMap, the messages are posted with notify after releasing it.
+func (notifier *webhookNotifier) crossedThresholds(ipPortMapMap *ipPortMapMap, labels *labelTable) []webhookMessage {
+	notifier.Lock()
+	defer notifier.Unlock()
+	var messages []webhookMessage
+	for ipAddress, ipPortMapStruct := range ipPortMapMap.ipPortMapMap {
+		if ipPortMapStruct.amountOfRequests < notifier.threshold || notifier.notified[ipAddress] {
+			continue
+		}
+		notifier.notified[ipAddress] = true
+
+		messages = append(messages, webhookMessage{
+			IPAddress: ipAddress,
+			Label:     labels.lookup(ipAddress),
+			Requests:  ipPortMapStruct.amountOfRequests,
+			Threshold: notifier.threshold,
+			Ports:     ipPortMapStruct.portCounts(),
+		})
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if messages[i].Requests != messages[j].Requests {
+			return messages[i].Requests > messages[j].Requests
+		}
+		return messages[i].IPAddress < messages[j].IPAddress
+	})
+	return messages
+}
+
+// notify posts the messages returned by crossedThresholds.
+func (notifier *webhookNotifier) notify(messages []webhookMessage) []error {
+	var errs []error
+	for _, message := range messages {
+		if err := notifier.post(message); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// reset forgets the notified IP addresses, so an address is notified again
+// when it reaches the threshold after the counters were reset.
+func (notifier *webhookNotifier) reset() {
+	notifier.Lock()
+	defer notifier.Unlock()
+	notifier.notified = make(map[string]bool)
+}
+
+// post sends a single message to the webhook.
+func (notifier *webhookNotifier) post(message webhookMessage) error {
+	var body interface{} = message
+	if notifier.format == "slack" {
+		var ports []string
+		for _, portNumber := range sortedByAmount(message.Ports, 5) {
+			ports = append(ports, fmt.Sprintf("%s (%d)", portNumber, message.Ports[portNumber]))
+		}
+		ipAddress := message.IPAddress
+		if message.Label != "" {
+			ipAddress += " (" + message.Label + ")"
+		}
+		body = map[string]string{
+			"text": fmt.Sprintf(":rotating_light: *%s* crossed %d blocked requests: %d requests, top ports %s",
+				ipAddress, message.Threshold, message.Requests, strings.Join(ports, ", ")),
+		}
+	}
+
+	if err := notifier.postJSON(body); err != nil {
+		return fmt.Errorf("webhook: %s: %v", message.IPAddress, err)
+	}
+	return nil
+}
+
+// postText sends a message that is not about an IP address, like a stale
+// log file, as {"text": "..."} in both formats.
+func (notifier *webhookNotifier) postText(text string) error {
+	if err := notifier.postJSON(map[string]string{"text": text}); err != nil {
+		return fmt.Errorf("webhook: %v", err)
+	}
+	return nil
+}
+
+// postJSON posts body as JSON to the webhook.
+func (notifier *webhookNotifier) postJSON(body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	response, err := notifier.client.Post(notifier.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Layout of the date and time fields of pfirewall.log.
+const windowsFirewallTimeLayout = "2006-01-02 15:04:05"
+
+// windowsTCPFlags maps the TCP flag letters of pfirewall.log to the flag
+// names of the kernel log, in the order of the kernel log.
+var windowsTCPFlags = []struct {
+	char byte
+	name string
+}{
+	{'U', "URG"}, {'A', "ACK"}, {'P', "PSH"}, {'R', "RST"}, {'S', "SYN"}, {'F', "FIN"},
+}
+
+// parseWindowsFirewall parses a line of the Windows Firewall log,
+// pfirewall.log, in its default field order:
+//
+//	#Fields: date time action protocol src-ip dst-ip src-port dst-port size tcpflags ...
+//	2024-01-05 10:00:01 DROP TCP 203.0.113.5 10.0.0.1 51515 3389 52 S 1234 0 8192 - - - RECEIVE 4
+//
+// The header lines starting with # are skipped. The times are local time.
+func parseWindowsFirewall(line []byte, entry *logEntry) bool {
+	if len(line) == 0 || line[0] == '#' {
+		return false
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) < 8 {
+		return false
+	}
+	timestamp, err := time.ParseInLocation(windowsFirewallTimeLayout, fields[0]+" "+fields[1], logLocation)
+	if err == nil {
+		entry.timestamp, entry.hasTimestamp = timestamp, true
+	}
+	entry.action = fields[2]
+	entry.protocol = fields[3]
+	entry.source = fields[4]
+	entry.destination = fields[5]
+	if len(fields) > 9 && fields[9] != "-" {
+		var flags []string
+		for _, flag := range windowsTCPFlags {
+			if strings.IndexByte(fields[9], flag.char) >= 0 {
+				flags = append(flags, flag.name)
+			}
+		}
+		entry.flags = strings.Join(flags, " ")
+	}
+	if !isPortNumber([]byte(fields[7])) {
+		return false
+	}
+	entry.port = fields[7]
+	return true
+}